@@ -6,10 +6,13 @@ import (
 	"github.com/pffreitas/optimizely-terraform-provider/optimizely"
 )
 
+// version is set by goreleaser at build time
+var version = "dev"
+
 func main() {
 	plugin.Serve(&plugin.ServeOpts{
 		ProviderFunc: func() *schema.Provider {
-			return optimizely.Provider()
+			return optimizely.New(version)
 		},
 	})
 }
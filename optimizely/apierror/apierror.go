@@ -0,0 +1,114 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIError is returned by the Optimizely client whenever the API answers
+// with a non-success status code.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	Field      string
+	RequestID  string
+	Method     string
+	Endpoint   string
+	Body       string
+}
+
+type errorResponse struct {
+	Code      interface{} `json:"code"`
+	Message   string      `json:"message"`
+	Field     string      `json:"field"`
+	UUID      string      `json:"uuid"`
+	RequestID string      `json:"request_id"`
+}
+
+// New builds an APIError from a failed response. Both the REST v2 and the
+// Flags v1 APIs answer with a {code, message, uuid} document; anything that
+// doesn't parse is kept verbatim in Message.
+func New(method, endpoint string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Method:     method,
+		Endpoint:   endpoint,
+		Body:       string(body),
+		RequestID:  resp.Header.Get("X-Request-Id"),
+	}
+
+	var errResp errorResponse
+	if err := json.Unmarshal(body, &errResp); err == nil {
+		if errResp.Code != nil {
+			apiErr.Code = fmt.Sprintf("%v", errResp.Code)
+		}
+		apiErr.Message = errResp.Message
+		apiErr.Field = errResp.Field
+
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = errResp.RequestID
+		}
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = errResp.UUID
+		}
+	}
+
+	if apiErr.Message == "" {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(resp.StatusCode)
+	}
+
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%s %s: HTTP status %d", e.Method, e.Endpoint, e.StatusCode)
+	if e.Code != "" {
+		fmt.Fprintf(&b, " (%s)", e.Code)
+	}
+	fmt.Fprintf(&b, ": %s", e.Message)
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " [request id: %s]", e.RequestID)
+	}
+
+	return b.String()
+}
+
+// As returns the APIError wrapped in err, if any.
+func As(err error) (*APIError, bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr, true
+	}
+	return nil, false
+}
+
+func hasStatus(err error, status int) bool {
+	apiErr, ok := As(err)
+	return ok && apiErr.StatusCode == status
+}
+
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+func IsConflict(err error) bool {
+	return hasStatus(err, http.StatusConflict)
+}
+
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+func IsBadRequest(err error) bool {
+	return hasStatus(err, http.StatusBadRequest) || hasStatus(err, http.StatusUnprocessableEntity)
+}
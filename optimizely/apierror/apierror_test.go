@@ -0,0 +1,41 @@
+package apierror
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestNewParsesOptimizelyErrorBody(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusBadRequest, Header: http.Header{}}
+	body := []byte(`{"code": "BAD_REQUEST", "message": "Invalid conditions", "field": "conditions", "uuid": "abc-123"}`)
+
+	err := New("POST", "https://api.optimizely.com/v2/audiences", resp, body)
+
+	if err.Code != "BAD_REQUEST" || err.Message != "Invalid conditions" || err.Field != "conditions" || err.RequestID != "abc-123" {
+		t.Errorf("unexpected APIError: %+v", err)
+	}
+
+	if !IsBadRequest(fmt.Errorf("wrapped: %w", err)) {
+		t.Errorf("expected a wrapped 400 to be recognized as a bad request")
+	}
+
+	diags := Diagnostics("Failed to create Audience in Optimizely", err, map[string]string{"conditions": "conditions"})
+	if len(diags) != 1 || diags[0].AttributePath == nil || diags[0].Summary != "Failed to create Audience in Optimizely" {
+		t.Errorf("unexpected diagnostics: %+v", diags)
+	}
+}
+
+func TestNewKeepsUnparseableBody(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusNotFound, Header: http.Header{"X-Request-Id": []string{"req-1"}}}
+
+	err := New("GET", "https://api.optimizely.com/v2/audiences/1", resp, []byte("Not Found"))
+
+	if !IsNotFound(err) || IsConflict(err) || IsRateLimited(err) {
+		t.Errorf("status helpers disagree with status %d", err.StatusCode)
+	}
+
+	if err.Message != "Not Found" || err.RequestID != "req-1" {
+		t.Errorf("unexpected APIError: %+v", err)
+	}
+}
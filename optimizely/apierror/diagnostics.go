@@ -0,0 +1,53 @@
+package apierror
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// Diagnostics turns err into an error diagnostic. API errors get their
+// status, code and request ID in the detail, and when Optimizely names the
+// offending field, fields maps it to the resource attribute the diagnostic
+// should point at.
+func Diagnostics(summary string, err error, fields map[string]string) diag.Diagnostics {
+	diagnostic := diag.Diagnostic{
+		Severity: diag.Error,
+		Summary:  summary,
+		Detail:   err.Error(),
+	}
+
+	if apiErr, ok := As(err); ok {
+		diagnostic.Detail = detail(apiErr)
+
+		if attr, ok := fields[apiErr.Field]; ok && apiErr.Field != "" {
+			diagnostic.AttributePath = cty.GetAttrPath(attr)
+		}
+	}
+
+	return diag.Diagnostics{diagnostic}
+}
+
+func detail(e *APIError) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Optimizely returned HTTP status %d", e.StatusCode)
+	if e.Code != "" {
+		fmt.Fprintf(&b, " (%s)", e.Code)
+	}
+	fmt.Fprintf(&b, " for %s %s", e.Method, e.Endpoint)
+
+	fmt.Fprintf(&b, "\n\n%s", e.Message)
+
+	if e.Field != "" {
+		fmt.Fprintf(&b, "\n\nField: %s", e.Field)
+	}
+
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, "\nRequest ID: %s", e.RequestID)
+	}
+
+	return b.String()
+}
@@ -1,8 +1,10 @@
 package audience
 
+import "context"
+
 type AudienceClient interface {
-	CreateAudience(aud Audience) (Audience, error)
-	GetAudience(audId string) (Audience, error)
-	ArchiveAudience(audId string) (Audience, error)
-	UpdateAudience(aud Audience) (Audience, error)
+	CreateAudience(ctx context.Context, aud Audience) (Audience, error)
+	GetAudience(ctx context.Context, audId string) (Audience, error)
+	ArchiveAudience(ctx context.Context, audId string) (Audience, error)
+	UpdateAudience(ctx context.Context, aud Audience) (Audience, error)
 }
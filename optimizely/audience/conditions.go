@@ -0,0 +1,519 @@
+package audience
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// maxGroupDepth is how deep condition_group blocks nest, schemas can't be
+// recursive.
+const maxGroupDepth = 4
+
+var (
+	operators = []string{"and", "or", "not"}
+
+	conditionTypes = []string{
+		"custom_attribute",
+		"third_party_dimension",
+		"browser_version",
+		"campaign",
+		"cookies",
+		"device",
+		"first_session",
+		"ip",
+		"language",
+		"location",
+		"platform",
+		"query",
+		"referrer",
+		"source_type",
+		"time_and_day",
+	}
+
+	matchTypes = []string{
+		"exact",
+		"exists",
+		"substring",
+		"gt",
+		"ge",
+		"lt",
+		"le",
+		"semver_eq",
+		"semver_gt",
+		"semver_ge",
+		"semver_lt",
+		"semver_le",
+	}
+
+	// numericMatchTypes compare numbers, their value is sent as one
+	numericMatchTypes = map[string]bool{"gt": true, "ge": true, "lt": true, "le": true}
+
+	valueTypes = []string{"string", "number", "boolean"}
+)
+
+// conditionGroup returns the schema of a group of conditions nesting groups
+// depth levels deep.
+func conditionGroup(depth int) *schema.Resource {
+	group := map[string]*schema.Schema{
+		"operator": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      "and",
+			ValidateFunc: validation.StringInSlice(operators, false),
+		},
+		"condition": {
+			Type:     schema.TypeList,
+			Optional: true,
+			Elem: &schema.Resource{
+				Schema: map[string]*schema.Schema{
+					"type": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Default:      "custom_attribute",
+						ValidateFunc: validation.StringInSlice(conditionTypes, false),
+					},
+					"name": {
+						Type:         schema.TypeString,
+						Required:     true,
+						ValidateFunc: validation.StringIsNotWhiteSpace,
+					},
+					"match_type": {
+						Type:         schema.TypeString,
+						Optional:     true,
+						Default:      "exact",
+						ValidateFunc: validation.StringInSlice(matchTypes, false),
+					},
+					"value": {
+						Type:     schema.TypeString,
+						Optional: true,
+					},
+					"value_type": {
+						Type:             schema.TypeString,
+						Optional:         true,
+						ValidateFunc:     validation.StringInSlice(valueTypes, false),
+						DiffSuppressFunc: suppressDefaultValueType,
+						Description:      "How value is sent, defaults to number for gt, ge, lt and le and to string otherwise",
+					},
+				},
+			},
+		},
+	}
+
+	if depth > 1 {
+		group["condition_group"] = &schema.Schema{
+			Type:     schema.TypeList,
+			Optional: true,
+			Elem:     conditionGroup(depth - 1),
+		}
+	}
+
+	return &schema.Resource{Schema: group}
+}
+
+func effectiveValueType(valueType, matchType string) string {
+	if valueType != "" {
+		return valueType
+	}
+
+	if numericMatchTypes[matchType] {
+		return "number"
+	}
+
+	return "string"
+}
+
+func conditionValueType(condition map[string]interface{}) string {
+	valueType, _ := condition["value_type"].(string)
+	return effectiveValueType(valueType, condition["match_type"].(string))
+}
+
+// suppressDefaultValueType hides the difference between an unset value_type
+// and the one it defaults to for the match type of its condition.
+func suppressDefaultValueType(k, old, new string, d *schema.ResourceData) bool {
+	matchType, _ := d.Get(strings.TrimSuffix(k, "value_type") + "match_type").(string)
+	return effectiveValueType(old, matchType) == effectiveValueType(new, matchType)
+}
+
+// buildConditions turns a condition_group block into Optimizely's condition
+// array: the operator, then the conditions, then the nested groups.
+func buildConditions(group map[string]interface{}) ([]interface{}, error) {
+	conditions := []interface{}{group["operator"].(string)}
+
+	for _, c := range group["condition"].([]interface{}) {
+		cMap, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		condition := map[string]interface{}{
+			"type":       cMap["type"].(string),
+			"name":       cMap["name"].(string),
+			"match_type": cMap["match_type"].(string),
+		}
+
+		if cMap["match_type"].(string) != "exists" {
+			value := cMap["value"].(string)
+
+			switch conditionValueType(cMap) {
+			case "number":
+				number, err := parseNumber(value)
+				if err != nil {
+					return nil, fmt.Errorf("condition %s: value %q isn't a number", cMap["name"], value)
+				}
+				condition["value"] = number
+			case "boolean":
+				boolean, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("condition %s: value %q isn't a boolean", cMap["name"], value)
+				}
+				condition["value"] = boolean
+			default:
+				condition["value"] = value
+			}
+		}
+
+		conditions = append(conditions, condition)
+	}
+
+	nested, _ := group["condition_group"].([]interface{})
+	for _, g := range nested {
+		gMap, ok := g.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		nestedConditions, err := buildConditions(gMap)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, nestedConditions)
+	}
+
+	return conditions, nil
+}
+
+// marshalConditionGroup returns the conditions JSON of a condition_group
+// attribute.
+func marshalConditionGroup(groups []interface{}) (string, error) {
+	group, ok := groups[0].(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("condition_group is empty")
+	}
+
+	conditions, err := buildConditions(group)
+	if err != nil {
+		return "", err
+	}
+
+	return marshalConditions(conditions)
+}
+
+// marshalConditions encodes conditions with sorted keys and no HTML escaping,
+// the canonical form conditions are kept in state.
+func marshalConditions(conditions interface{}) (string, error) {
+	buf := new(bytes.Buffer)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(conditions); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// decodeConditions decodes a conditions JSON with its numbers in canonical
+// form. Numbers are kept as json.Number, float64 would round integers above
+// 2^53.
+func decodeConditions(conditions string) (interface{}, error) {
+	dec := json.NewDecoder(strings.NewReader(conditions))
+	dec.UseNumber()
+
+	var tree interface{}
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("unexpected data after the conditions")
+	}
+
+	return canonicalNumbers(tree)
+}
+
+func canonicalNumbers(tree interface{}) (interface{}, error) {
+	switch v := tree.(type) {
+	case json.Number:
+		return canonicalNumber(v)
+	case []interface{}:
+		for i, item := range v {
+			canonical, err := canonicalNumbers(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = canonical
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			canonical, err := canonicalNumbers(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = canonical
+		}
+	}
+
+	return tree, nil
+}
+
+// canonicalNumber returns the shortest exact decimal form of a number, so 18,
+// 18.0 and 1.8e1 all read 18.
+func canonicalNumber(number json.Number) (json.Number, error) {
+	r, ok := new(big.Rat).SetString(string(number))
+	if !ok {
+		return "", fmt.Errorf("invalid number %s", number)
+	}
+
+	if r.IsInt() {
+		return json.Number(r.Num().String()), nil
+	}
+
+	// a decimal's denominator divides a power of ten, its exponent is the
+	// number of places
+	places := 0
+	ten := big.NewInt(10)
+	for pow := big.NewInt(1); new(big.Int).Rem(pow, r.Denom()).Sign() != 0; pow.Mul(pow, ten) {
+		places++
+	}
+
+	return json.Number(r.FloatString(places)), nil
+}
+
+// parseNumber returns the canonical form of a condition value declared as a
+// number.
+func parseNumber(value string) (json.Number, error) {
+	tree, err := decodeConditions(value)
+	if err != nil {
+		return "", err
+	}
+
+	number, ok := tree.(json.Number)
+	if !ok {
+		return "", fmt.Errorf("%q isn't a number", value)
+	}
+
+	return number, nil
+}
+
+// normalizeConditions returns the canonical form of a conditions JSON, so
+// documents differing only in key order, whitespace or number formatting
+// (18 and 18.0) compare equal.
+func normalizeConditions(conditions string) (string, error) {
+	tree, err := decodeConditions(conditions)
+	if err != nil {
+		return "", err
+	}
+
+	return marshalConditions(tree)
+}
+
+// conditionsStateFunc stores conditions normalized, malformed JSON is left as
+// is for validateConditionsJSON to report.
+func conditionsStateFunc(v interface{}) string {
+	normalized, err := normalizeConditions(v.(string))
+	if err != nil {
+		return v.(string)
+	}
+
+	return normalized
+}
+
+// suppressEquivalentConditions hides differences between conditions that
+// normalize to the same JSON.
+func suppressEquivalentConditions(k, old, new string, d *schema.ResourceData) bool {
+	oldNormalized, err := normalizeConditions(old)
+	if err != nil {
+		return false
+	}
+
+	newNormalized, err := normalizeConditions(new)
+	if err != nil {
+		return false
+	}
+
+	return oldNormalized == newNormalized
+}
+
+// validateConditionsJSON rejects conditions that aren't a JSON array, which
+// is how Optimizely combines them.
+func validateConditionsJSON(v interface{}, k string) ([]string, []error) {
+	tree, err := decodeConditions(v.(string))
+	if err != nil {
+		return nil, []error{fmt.Errorf("expected %s to be a JSON array of conditions: %s", k, err)}
+	}
+
+	if _, ok := tree.([]interface{}); !ok {
+		return nil, []error{fmt.Errorf("expected %s to be a JSON array of conditions", k)}
+	}
+
+	return nil, nil
+}
+
+// flattenConditions turns Optimizely's condition array back into a
+// condition_group block. Like Optimizely, an array without operator combines
+// its items with "or"; groups nested deeper than maxGroupDepth are left out.
+func flattenConditions(conditions []interface{}, depth int) map[string]interface{} {
+	operator := "or"
+	flatConditions := []interface{}{}
+	groups := []interface{}{}
+
+	for i, item := range conditions {
+		switch c := item.(type) {
+		case string:
+			if i == 0 {
+				operator = c
+			}
+
+		case map[string]interface{}:
+			condition := map[string]interface{}{
+				"type":       c["type"],
+				"name":       c["name"],
+				"match_type": "exact",
+				"value":      "",
+				"value_type": "string",
+			}
+
+			if matchType, ok := c["match_type"].(string); ok {
+				condition["match_type"] = matchType
+			}
+
+			switch value := c["value"].(type) {
+			case string:
+				condition["value"] = value
+			case json.Number:
+				condition["value"] = value.String()
+				condition["value_type"] = "number"
+			case bool:
+				condition["value"] = strconv.FormatBool(value)
+				condition["value_type"] = "boolean"
+			}
+
+			flatConditions = append(flatConditions, condition)
+
+		case []interface{}:
+			if depth > 1 {
+				groups = append(groups, flattenConditions(c, depth-1))
+			}
+		}
+	}
+
+	group := map[string]interface{}{
+		"operator":  operator,
+		"condition": flatConditions,
+	}
+
+	if depth > 1 {
+		group["condition_group"] = groups
+	}
+
+	return group
+}
+
+// validateConditionGroup checks the condition_group block at plan time and
+// plans the conditions JSON it serializes to.
+func validateConditionGroup(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	groups := d.Get("condition_group").([]interface{})
+	if len(groups) == 0 {
+		return nil
+	}
+
+	// a value taken from another resource may only be known at apply time,
+	// wherever it's nested
+	config := d.GetRawConfig()
+	if !d.NewValueKnown("condition_group") || (!config.IsNull() && !config.GetAttr("condition_group").IsWhollyKnown()) {
+		return d.SetNewComputed("conditions")
+	}
+
+	group, ok := groups[0].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	if err := validateGroup(group); err != nil {
+		return err
+	}
+
+	conditions, err := marshalConditionGroup(groups)
+	if err != nil {
+		return err
+	}
+
+	if d.Get("conditions").(string) != conditions {
+		return d.SetNew("conditions", conditions)
+	}
+
+	return nil
+}
+
+// resolveConditions returns the conditions JSON of the audience, serialized
+// from condition_group when it is set.
+func resolveConditions(d *schema.ResourceData) (string, error) {
+	if groups := d.Get("condition_group").([]interface{}); len(groups) > 0 {
+		return marshalConditionGroup(groups)
+	}
+
+	return d.Get("conditions").(string), nil
+}
+
+func validateGroup(group map[string]interface{}) error {
+	conditions := group["condition"].([]interface{})
+	nested, _ := group["condition_group"].([]interface{})
+
+	if group["operator"] == "not" && len(conditions)+len(nested) != 1 {
+		return fmt.Errorf("a \"not\" condition_group negates exactly one condition or group, got %d", len(conditions)+len(nested))
+	}
+
+	for _, c := range conditions {
+		cMap, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		name := cMap["name"].(string)
+		matchType := cMap["match_type"].(string)
+		valueType := conditionValueType(cMap)
+
+		if matchType == "exists" {
+			if cMap["value"].(string) != "" {
+				return fmt.Errorf("condition %s: exists conditions take no value", name)
+			}
+			continue
+		}
+
+		if cMap["value"].(string) == "" {
+			return fmt.Errorf("condition %s: %s conditions need a value", name, matchType)
+		}
+
+		if numericMatchTypes[matchType] && valueType != "number" {
+			return fmt.Errorf("condition %s: %s conditions compare numbers, got value_type %s", name, matchType, valueType)
+		}
+	}
+
+	for _, g := range nested {
+		if gMap, ok := g.(map[string]interface{}); ok {
+			if err := validateGroup(gMap); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -1,14 +1,18 @@
 package audience
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"strconv"
+	"time"
 
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/apierror"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/project"
 )
 
 type Audience struct {
@@ -20,14 +24,21 @@ type Audience struct {
 	Archived    bool   `json:"archived"`
 }
 
+var audienceFields = map[string]string{
+	"project_id":  "project",
+	"name":        "name",
+	"description": "description",
+	"conditions":  "conditions",
+}
+
 func ResourceAudience() *schema.Resource {
 	return &schema.Resource{
 		Schema: map[string]*schema.Schema{
 			"project": {
 				Type:        schema.TypeInt,
-				Required:    true,
-				Description: "Project ID",
-				Elem:        &schema.Schema{Type: schema.TypeInt},
+				Optional:    true,
+				Computed:    true,
+				Description: "Project ID, defaults to the provider project_id",
 			},
 			"id": {
 				Type:        schema.TypeString,
@@ -45,37 +56,61 @@ func ResourceAudience() *schema.Resource {
 				Description: "A short description of the Audience",
 			},
 			"conditions": {
-				Type:        schema.TypeString,
-				Optional:    true,
-				Description: "A string defining the targeting rules for an Audience",
+				Type:             schema.TypeString,
+				Optional:         true,
+				Computed:         true,
+				ConflictsWith:    []string{"condition_group"},
+				ValidateFunc:     validateConditionsJSON,
+				StateFunc:        conditionsStateFunc,
+				DiffSuppressFunc: suppressEquivalentConditions,
+				Description:      "A string defining the targeting rules for an Audience",
 			},
+			"condition_group": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MaxItems:      1,
+				ConflictsWith: []string{"conditions"},
+				Description:   "The targeting rules for an Audience, as conditions combined with and, or and not",
+				Elem:          conditionGroup(maxGroupDepth),
+			},
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
 		},
+		CustomizeDiff: customdiff.All(
+			project.SetDefaultProject,
+			validateConditionGroup,
+		),
 		CreateContext: resourceAudienceCreate,
 		ReadContext:   resourceAudienceRead,
 		UpdateContext: resourceAudienceUpdate,
 		DeleteContext: resourceAudienceDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceAudienceImport,
+		},
 	}
 }
 
 func resourceAudienceCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
 	client := m.(AudienceClient)
 
+	conditions, err := resolveConditions(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	aud := Audience{
 		ProjectId:   d.Get("project").(int),
 		Name:        d.Get("name").(string),
 		Description: d.Get("description").(string),
-		Conditions:  d.Get("conditions").(string),
+		Conditions:  conditions,
 	}
 
-	audResp, err := client.CreateAudience(aud)
+	audResp, err := client.CreateAudience(ctx, aud)
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to create Audience in Optimizely: %+v", err),
-		})
-
-		return diags
+		return apierror.Diagnostics("Failed to create Audience in Optimizely", err, audienceFields)
 	}
 
 	d.SetId(strconv.FormatInt(audResp.ID, 10))
@@ -86,31 +121,56 @@ func resourceAudienceRead(ctx context.Context, d *schema.ResourceData, m interfa
 	var diags diag.Diagnostics
 
 	client := m.(AudienceClient)
-	aud, err := client.GetAudience(d.Id())
+	aud, err := client.GetAudience(ctx, d.Id())
+	if apierror.IsNotFound(err) {
+		tflog.Warn(ctx, "Audience not found in Optimizely, removing it from state", map[string]interface{}{
+			"id": d.Id(),
+		})
+
+		d.SetId("")
+		return diags
+	}
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to create Audience in Optimizely: %+v", err),
+		return apierror.Diagnostics("Failed to read Audience from Optimizely", err, audienceFields)
+	}
+
+	// Optimizely never deletes audiences, an archived one is as good as gone.
+	if aud.Archived {
+		tflog.Warn(ctx, "Audience archived in Optimizely, removing it from state", map[string]interface{}{
+			"id": d.Id(),
 		})
 
+		d.SetId("")
 		return diags
 	}
 
-	compactConditions := new(bytes.Buffer)
-	err = json.Compact(compactConditions, []byte(aud.Conditions))
+	conditions, err := normalizeConditions(aud.Conditions)
 	if err != nil {
 		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to compact conditions: %s --- %+v", aud.Conditions, err),
+			Severity:      diag.Error,
+			Summary:       "Failed to parse Audience conditions returned by Optimizely",
+			Detail:        fmt.Sprintf("%s\n\n%+v", aud.Conditions, err),
+			AttributePath: cty.GetAttrPath("conditions"),
 		})
 
 		return diags
 	}
 
 	d.SetId(strconv.FormatInt(aud.ID, 10))
+	d.Set("project", aud.ProjectId)
 	d.Set("name", aud.Name)
 	d.Set("description", aud.Description)
-	d.Set("conditions", compactConditions.String())
+	d.Set("conditions", conditions)
+
+	// audiences managed with condition_group get it back from the conditions,
+	// so edits in the UI show up as drift
+	if len(d.Get("condition_group").([]interface{})) > 0 {
+		if tree, err := decodeConditions(aud.Conditions); err == nil {
+			if conditions, ok := tree.([]interface{}); ok {
+				d.Set("condition_group", []interface{}{flattenConditions(conditions, maxGroupDepth)})
+			}
+		}
+	}
 
 	return diags
 }
@@ -123,47 +183,49 @@ func resourceAudienceUpdate(ctx context.Context, d *schema.ResourceData, m inter
 	if err != nil {
 		diags = append(diags, diag.Diagnostic{
 			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to parse Audicence ID: %s,  %+v", d.Id(), err),
+			Summary:  "Failed to parse Audience ID",
+			Detail:   fmt.Sprintf("%s: %+v", d.Id(), err),
 		})
 
 		return diags
 	}
 
+	conditions, err := resolveConditions(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
 	aud := Audience{
 		ProjectId:   d.Get("project").(int),
 		ID:          audId,
 		Name:        d.Get("name").(string),
 		Description: d.Get("description").(string),
-		Conditions:  d.Get("conditions").(string),
+		Conditions:  conditions,
 	}
 
-	_, err = client.UpdateAudience(aud)
+	_, err = client.UpdateAudience(ctx, aud)
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to create Audience in Optimizely: %+v", err),
-		})
-
-		return diags
+		return apierror.Diagnostics("Failed to update Audience in Optimizely", err, audienceFields)
 	}
 
 	return resourceAudienceRead(ctx, d, m)
 }
 
-func resourceAudienceDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
+func resourceAudienceImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	if _, err := strconv.ParseInt(d.Id(), 10, 64); err != nil {
+		return nil, fmt.Errorf("expected the numeric ID of an Audience, got %q", d.Id())
+	}
 
+	return []*schema.ResourceData{d}, nil
+}
+
+func resourceAudienceDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	client := m.(AudienceClient)
 
-	_, err := client.ArchiveAudience(d.Id())
+	_, err := client.ArchiveAudience(ctx, d.Id())
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to create Audience in Optimizely: %+v", err),
-		})
-
-		return diags
+		return apierror.Diagnostics("Failed to archive Audience in Optimizely", err, audienceFields)
 	}
 
-	return resourceAudienceRead(ctx, d, m)
+	return nil
 }
@@ -2,19 +2,20 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"strconv"
 
 	"github.com/pffreitas/optimizely-terraform-provider/optimizely/audience"
 )
 
-func (c OptimizelyClient) CreateAudience(aud audience.Audience) (audience.Audience, error) {
+func (c OptimizelyClient) CreateAudience(ctx context.Context, aud audience.Audience) (audience.Audience, error) {
 	postBody, err := json.Marshal(aud)
 	if err != nil {
 		return aud, err
 	}
 
-	respBody, err := c.sendHttpRequest("POST", "v2/audiences", bytes.NewBuffer(postBody))
+	respBody, err := c.sendHttpRequest(ctx, "POST", c.restURL("audiences"), bytes.NewBuffer(postBody))
 	if err != nil {
 		return aud, err
 	}
@@ -25,9 +26,9 @@ func (c OptimizelyClient) CreateAudience(aud audience.Audience) (audience.Audien
 	return audienceResp, nil
 }
 
-func (c OptimizelyClient) GetAudience(audId string) (audience.Audience, error) {
+func (c OptimizelyClient) GetAudience(ctx context.Context, audId string) (audience.Audience, error) {
 
-	respBody, err := c.sendHttpRequest("GET", fmt.Sprintf("v2/audiences/%s", audId), nil)
+	respBody, err := c.sendHttpRequest(ctx, "GET", c.restURL("audiences", audId), nil)
 	if err != nil {
 		return audience.Audience{}, err
 	}
@@ -38,7 +39,7 @@ func (c OptimizelyClient) GetAudience(audId string) (audience.Audience, error) {
 	return audienceResp, nil
 }
 
-func (c OptimizelyClient) ArchiveAudience(audId string) (audience.Audience, error) {
+func (c OptimizelyClient) ArchiveAudience(ctx context.Context, audId string) (audience.Audience, error) {
 	postBody, err := json.Marshal(map[string]interface{}{
 		"archived": true,
 	})
@@ -46,7 +47,7 @@ func (c OptimizelyClient) ArchiveAudience(audId string) (audience.Audience, erro
 		return audience.Audience{}, err
 	}
 
-	respBody, err := c.sendHttpRequest("PATCH", fmt.Sprintf("v2/audiences/%s", audId), bytes.NewBuffer(postBody))
+	respBody, err := c.sendHttpRequest(ctx, "PATCH", c.restURL("audiences", audId), bytes.NewBuffer(postBody))
 	if err != nil {
 		return audience.Audience{}, err
 	}
@@ -57,13 +58,13 @@ func (c OptimizelyClient) ArchiveAudience(audId string) (audience.Audience, erro
 	return audienceResp, nil
 }
 
-func (c OptimizelyClient) UpdateAudience(aud audience.Audience) (audience.Audience, error) {
+func (c OptimizelyClient) UpdateAudience(ctx context.Context, aud audience.Audience) (audience.Audience, error) {
 	postBody, err := json.Marshal(aud)
 	if err != nil {
 		return audience.Audience{}, err
 	}
 
-	respBody, err := c.sendHttpRequest("PATCH", fmt.Sprintf("v2/audiences/%d", aud.ID), bytes.NewBuffer(postBody))
+	respBody, err := c.sendHttpRequest(ctx, "PATCH", c.restURL("audiences", strconv.FormatInt(aud.ID, 10)), bytes.NewBuffer(postBody))
 	if err != nil {
 		return audience.Audience{}, err
 	}
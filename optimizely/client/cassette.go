@@ -0,0 +1,269 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+const (
+	// RecordModeEnvVar switches the transport to record or replay API
+	// interactions, to or from the file named by CassetteEnvVar.
+	RecordModeEnvVar = "OPTIMIZELY_RECORD"
+	CassetteEnvVar   = "OPTIMIZELY_CASSETTE"
+
+	RecordMode = "record"
+	ReplayMode = "replay"
+
+	scrubbed = "REDACTED"
+)
+
+// recordedHeaders are the only response headers kept in a cassette.
+var recordedHeaders = []string{"Content-Type", "Retry-After", "X-Request-Id"}
+
+type Interaction struct {
+	Method       string            `json:"method"`
+	URL          string            `json:"url"`
+	RequestBody  string            `json:"request_body,omitempty"`
+	Status       int               `json:"status"`
+	Headers      map[string]string `json:"headers,omitempty"`
+	ResponseBody string            `json:"response_body,omitempty"`
+
+	replayed bool
+}
+
+type cassette struct {
+	mu           sync.Mutex
+	path         string
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// cassettes are shared by every transport of the process until
+// CloseCassette: Terraform configures the provider again for each command of
+// a test, and all of them must append to, or replay from, the same cassette.
+var (
+	cassettesMu sync.Mutex
+	cassettes   = make(map[string]*cassette)
+)
+
+func loadCassette(mode, path string) (*cassette, error) {
+	cassettesMu.Lock()
+	defer cassettesMu.Unlock()
+
+	if c, ok := cassettes[path]; ok {
+		return c, nil
+	}
+
+	c := &cassette{path: path, Interactions: []*Interaction{}}
+
+	// a test making no API calls still gets a cassette, so its replay finds
+	// one
+	if mode == RecordMode {
+		if err := c.save(); err != nil {
+			return nil, fmt.Errorf("failed to save cassette: %w", err)
+		}
+	}
+
+	if mode == ReplayMode {
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cassette: %w", err)
+		}
+
+		if err := json.Unmarshal(content, c); err != nil {
+			return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+		}
+	}
+
+	cassettes[path] = c
+	return c, nil
+}
+
+// CloseCassette forgets the cassette at path, so the next transport using it
+// starts a new recording or replays it from the first interaction.
+func CloseCassette(path string) {
+	cassettesMu.Lock()
+	defer cassettesMu.Unlock()
+
+	delete(cassettes, path)
+}
+
+func (c *cassette) save() error {
+	content, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(c.path, append(content, '\n'), 0644)
+}
+
+// cassetteTransport records API interactions into a cassette, with secrets
+// scrubbed, or replays them without touching the network.
+type cassetteTransport struct {
+	mode     string
+	cassette *cassette
+	next     http.RoundTripper
+	secrets  []string
+}
+
+func newCassetteTransport(mode, path string, next http.RoundTripper, secrets []string) (http.RoundTripper, error) {
+	if mode != RecordMode && mode != ReplayMode {
+		return nil, fmt.Errorf("%s must be %q or %q, got %q", RecordModeEnvVar, RecordMode, ReplayMode, mode)
+	}
+
+	if path == "" {
+		return nil, fmt.Errorf("%s must name the cassette file when %s is set", CassetteEnvVar, RecordModeEnvVar)
+	}
+
+	c, err := loadCassette(mode, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cassetteTransport{mode: mode, cassette: c, next: next, secrets: secrets}, nil
+}
+
+func (t *cassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	if t.mode == ReplayMode {
+		return t.replay(req, reqBody)
+	}
+
+	return t.record(req, reqBody)
+}
+
+func (t *cassetteTransport) record(req *http.Request, reqBody []byte) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	interaction := &Interaction{
+		Method:       req.Method,
+		URL:          t.scrub(req.URL.String()),
+		RequestBody:  t.scrub(string(reqBody)),
+		Status:       resp.StatusCode,
+		Headers:      make(map[string]string),
+		ResponseBody: t.scrub(string(respBody)),
+	}
+
+	for _, header := range recordedHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			interaction.Headers[header] = value
+		}
+	}
+
+	t.cassette.mu.Lock()
+	defer t.cassette.mu.Unlock()
+
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	if err := t.cassette.save(); err != nil {
+		return nil, fmt.Errorf("failed to save cassette: %w", err)
+	}
+
+	return resp, nil
+}
+
+// replay answers with the first not yet replayed interaction for the same
+// method, URL and request body. Terraform creates independent resources in
+// parallel, so requests to the same URL may come in another order than
+// recorded; when none has the same body, a change in the payloads sent to
+// Optimizely fails the test.
+func (t *cassetteTransport) replay(req *http.Request, reqBody []byte) (*http.Response, error) {
+	t.cassette.mu.Lock()
+	defer t.cassette.mu.Unlock()
+
+	url := t.scrub(req.URL.String())
+	body := t.scrub(string(reqBody))
+
+	var differing *Interaction
+	for _, interaction := range t.cassette.Interactions {
+		if interaction.replayed || interaction.Method != req.Method || interaction.URL != url {
+			continue
+		}
+
+		if !sameBody(interaction.RequestBody, body) {
+			if differing == nil {
+				differing = interaction
+			}
+			continue
+		}
+
+		interaction.replayed = true
+
+		header := make(http.Header)
+		for name, value := range interaction.Headers {
+			header.Set(name, value)
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Status, http.StatusText(interaction.Status)),
+			StatusCode:    interaction.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(strings.NewReader(interaction.ResponseBody)),
+			ContentLength: int64(len(interaction.ResponseBody)),
+			Request:       req,
+		}, nil
+	}
+
+	if differing != nil {
+		return nil, fmt.Errorf("%s %s: request body differs from cassette %s\n\nrecorded: %s\nsent:     %s", req.Method, url, t.cassette.path, differing.RequestBody, body)
+	}
+
+	return nil, fmt.Errorf("%s %s: no interaction left in cassette %s", req.Method, url, t.cassette.path)
+}
+
+func (t *cassetteTransport) scrub(value string) string {
+	for _, secret := range t.secrets {
+		if secret != "" {
+			value = strings.ReplaceAll(value, secret, scrubbed)
+		}
+	}
+	return value
+}
+
+// sameBody compares JSON bodies structurally, so key order and whitespace
+// don't matter.
+func sameBody(recorded, sent string) bool {
+	if recorded == sent {
+		return true
+	}
+
+	var recordedJSON, sentJSON interface{}
+	if json.Unmarshal([]byte(recorded), &recordedJSON) != nil || json.Unmarshal([]byte(sent), &sentJSON) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(recordedJSON, sentJSON)
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/audience"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/internal/fakeapi"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+	ctx := context.Background()
+
+	server := fakeapi.NewServer()
+
+	recorder := fakeClient(t, server)
+	httpClient, err := NewHTTPClient(TransportConfig{RecordMode: RecordMode, Cassette: cassette, Secrets: []string{server.Token}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder.HTTPClient = httpClient
+
+	created, err := recorder.CreateAudience(ctx, audience.Audience{ProjectId: 1, Name: "US", Conditions: `["and"]`})
+	if err != nil {
+		t.Fatalf("CreateAudience: %s", err)
+	}
+
+	server.Close()
+	CloseCassette(cassette)
+
+	// replaying twice, as go test -count=2 does, starts over each time
+	for run := 0; run < 2; run++ {
+		replayer := testClient(t, server.URL)
+		replayer.Token = "another-token"
+		httpClient, err = NewHTTPClient(TransportConfig{RecordMode: ReplayMode, Cassette: cassette})
+		if err != nil {
+			t.Fatal(err)
+		}
+		replayer.HTTPClient = httpClient
+
+		replayed, err := replayer.CreateAudience(ctx, audience.Audience{ProjectId: 1, Name: "US", Conditions: `["and"]`})
+		if err != nil {
+			t.Fatalf("replayed CreateAudience: %s", err)
+		}
+
+		if replayed.ID != created.ID {
+			t.Errorf("expected the recorded audience %d, got %d", created.ID, replayed.ID)
+		}
+
+		_, err = replayer.CreateAudience(ctx, audience.Audience{ProjectId: 1, Name: "US", Conditions: `["and"]`})
+		if err == nil || !strings.Contains(err.Error(), "no interaction left") {
+			t.Errorf("expected the cassette to be exhausted, got %v", err)
+		}
+
+		CloseCassette(cassette)
+	}
+}
+
+func TestCassetteReplayRejectsChangedPayloads(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+	writeFile(t, cassette, `{"interactions": [{"method": "POST", "url": "https://api.optimizely.com/v2/audiences", "request_body": "{\"name\": \"US\", \"project_id\": 1}", "status": 201, "response_body": "{\"id\": 1}"}]}`)
+
+	c := testClient(t, DefaultRestAPI)
+	httpClient, err := NewHTTPClient(TransportConfig{RecordMode: ReplayMode, Cassette: cassette})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.HTTPClient = httpClient
+
+	_, err = c.sendHttpRequest(context.Background(), "POST", c.restURL("audiences"), strings.NewReader(`{"name": "BR", "project_id": 1}`))
+	if err == nil || !strings.Contains(err.Error(), "request body differs") {
+		t.Errorf("expected a request body mismatch, got %v", err)
+	}
+}
+
+func TestCassetteReplayOutOfOrder(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+	writeFile(t, cassette, `{"interactions": [
+		{"method": "POST", "url": "https://api.optimizely.com/v2/audiences", "request_body": "{\"name\": \"US\"}", "status": 201, "response_body": "{\"id\": 1}"},
+		{"method": "POST", "url": "https://api.optimizely.com/v2/audiences", "request_body": "{\"name\": \"BR\"}", "status": 201, "response_body": "{\"id\": 2}"}
+	]}`)
+
+	c := testClient(t, DefaultRestAPI)
+	httpClient, err := NewHTTPClient(TransportConfig{RecordMode: ReplayMode, Cassette: cassette})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.HTTPClient = httpClient
+
+	// parallel creates may reach the API in another order than recorded
+	for _, want := range []struct{ name, id string }{{"BR", "2"}, {"US", "1"}} {
+		respBody, err := c.sendHttpRequest(context.Background(), "POST", c.restURL("audiences"), strings.NewReader(`{"name": "`+want.name+`"}`))
+		if err != nil {
+			t.Fatalf("%s: %s", want.name, err)
+		}
+
+		if got := string(respBody); got != `{"id": `+want.id+`}` {
+			t.Errorf("%s: got %s", want.name, got)
+		}
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,44 +1,146 @@
 package client
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/apierror"
 )
 
 type OptimizelyClient struct {
-	Address string
-	Token   string
+	RestAPI      *url.URL
+	FlagsAPI     *url.URL
+	Token        string
+	UserAgent    string
+	HTTPClient   *http.Client
+	ProjectId    int
+	MaxRetries   int
+	RetryMaxWait time.Duration
+}
+
+// DefaultProjectId is the provider level project, used by resources that
+// don't set their own.
+func (c OptimizelyClient) DefaultProjectId() int {
+	return c.ProjectId
 }
 
-func (c OptimizelyClient) sendHttpRequest(method, url string, body io.Reader) ([]byte, error) {
-	req, err := http.NewRequest(method, fmt.Sprintf("%s/%s", c.Address, url), body)
+func (c OptimizelyClient) sendHttpRequest(ctx context.Context, method, endpoint string, body io.Reader) ([]byte, error) {
+	// the body is buffered so it can be replayed on every retry attempt
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = ioutil.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ctx = c.logContext(ctx)
+
+	for attempt := 0; ; attempt++ {
+		respBody, resp, err := c.doHttpRequest(ctx, method, endpoint, reqBody, body != nil)
+
+		if attempt < c.MaxRetries && shouldRetry(method, resp, err) {
+			wait := retryBackoff(attempt, resp, c.RetryMaxWait)
+
+			fields := map[string]interface{}{
+				"method":  method,
+				"url":     endpoint,
+				"attempt": attempt + 1,
+				"wait_ms": wait.Milliseconds(),
+			}
+			if resp != nil {
+				fields["status"] = resp.StatusCode
+			}
+			if err != nil {
+				fields["error"] = err.Error()
+			}
+			tflog.SubsystemWarn(ctx, logSubsystem, "Retrying Optimizely API request", fields)
+
+			if err := sleepContext(ctx, wait); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode >= 400 {
+			return nil, apierror.New(method, endpoint, resp, respBody)
+		}
+
+		return respBody, nil
+	}
+}
+
+func (c OptimizelyClient) doHttpRequest(ctx context.Context, method, endpoint string, body []byte, hasBody bool) ([]byte, *http.Response, error) {
+	var reqBody io.Reader
+	if hasBody {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, reqBody)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.Token))
 
-	if body != nil {
+	if hasBody {
 		req.Header.Set("Content-type", "application/json")
 	}
 
-	httpClient := http.Client{}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	fields := map[string]interface{}{
+		"method": method,
+		"url":    endpoint,
+	}
+
+	tflog.SubsystemDebug(ctx, logSubsystem, "Sending Optimizely API request", fields)
+	tflog.SubsystemTrace(ctx, logSubsystem, "Optimizely API request details", fields, map[string]interface{}{
+		"headers": redactHeaders(req.Header),
+		"body":    string(body),
+	})
+
+	start := time.Now()
 	resp, err := httpClient.Do(req)
+	fields["latency_ms"] = time.Since(start).Milliseconds()
 	if err != nil {
-		return nil, err
+		tflog.SubsystemDebug(ctx, logSubsystem, "Optimizely API request failed", fields, map[string]interface{}{
+			"error": err.Error(),
+		})
+		return nil, nil, err
 	}
 
 	defer resp.Body.Close()
 	respBody, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, resp, err
 	}
 
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("HTTP status %d\n\n%s", resp.StatusCode, respBody)
-	}
+	fields["status"] = resp.StatusCode
+	tflog.SubsystemDebug(ctx, logSubsystem, "Received Optimizely API response", fields)
+	tflog.SubsystemTrace(ctx, logSubsystem, "Optimizely API response details", fields, map[string]interface{}{
+		"headers": redactHeaders(resp.Header),
+		"body":    string(respBody),
+	})
 
-	return respBody, nil
+	return respBody, resp, nil
 }
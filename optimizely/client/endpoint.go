@@ -0,0 +1,100 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	DefaultRestAPI  = "https://api.optimizely.com/v2"
+	DefaultFlagsAPI = "https://api.optimizely.com/flags/v1"
+
+	restAPIPath  = "/v2"
+	flagsAPIPath = "/flags/v1"
+)
+
+// ParseEndpoint validates the base URL of one of the Optimizely APIs.
+func ParseEndpoint(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", raw, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid endpoint %q: scheme must be http or https", raw)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid endpoint %q: missing host", raw)
+	}
+
+	if u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("invalid endpoint %q: query and fragment are not allowed", raw)
+	}
+
+	return u, nil
+}
+
+// EndpointsFromHost derives the REST v2 and Flags v1 base URLs from a single
+// API host. Hosts already pointing at the REST API, like
+// https://api.optimizely.com/v2, are accepted as well.
+func EndpointsFromHost(host string) (restAPI string, flagsAPI string, err error) {
+	u, err := ParseEndpoint(host)
+	if err != nil {
+		return "", "", err
+	}
+
+	root := *u
+	root.Path = strings.TrimSuffix(strings.TrimRight(u.Path, "/"), restAPIPath)
+	root.RawPath = ""
+
+	return joinURL(&root, "v2"), joinURL(&root, "flags", "v1"), nil
+}
+
+// joinURL appends path segments to base, escaping each one so keys can't
+// change the shape of the path.
+func joinURL(base *url.URL, segments ...string) string {
+	u := *base
+
+	path := strings.TrimRight(u.Path, "/")
+	rawPath := strings.TrimRight(u.EscapedPath(), "/")
+
+	for _, segment := range segments {
+		path += "/" + segment
+		rawPath += "/" + url.PathEscape(segment)
+	}
+
+	u.Path = path
+	u.RawPath = rawPath
+
+	return u.String()
+}
+
+func (c OptimizelyClient) restURL(segments ...string) string {
+	return joinURL(c.RestAPI, segments...)
+}
+
+func (c OptimizelyClient) flagsURL(segments ...string) string {
+	return joinURL(c.FlagsAPI, segments...)
+}
+
+// flagsPageURL resolves the next_url of a Flags API list, given relative to
+// the Flags API base URL.
+func (c OptimizelyClient) flagsPageURL(next string) (string, error) {
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", fmt.Errorf("invalid next page URL %q: %w", next, err)
+	}
+
+	if ref.IsAbs() {
+		return next, nil
+	}
+
+	u := *c.FlagsAPI
+	u.Path = strings.TrimRight(u.Path, "/") + "/" + strings.TrimLeft(ref.Path, "/")
+	u.RawPath = ""
+	u.RawQuery = ref.RawQuery
+
+	return u.String(), nil
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"testing"
+)
+
+func testClient(t *testing.T, host string) OptimizelyClient {
+	restAPI, flagsAPI, err := EndpointsFromHost(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restURL, _ := ParseEndpoint(restAPI)
+	flagsURL, _ := ParseEndpoint(flagsAPI)
+
+	return OptimizelyClient{RestAPI: restURL, FlagsAPI: flagsURL}
+}
+
+func TestEndpointsFromHost(t *testing.T) {
+	cases := []struct {
+		host     string
+		restAPI  string
+		flagsAPI string
+	}{
+		{"https://api.optimizely.com", "https://api.optimizely.com/v2", "https://api.optimizely.com/flags/v1"},
+		{"https://api.optimizely.com/", "https://api.optimizely.com/v2", "https://api.optimizely.com/flags/v1"},
+		{"https://api.optimizely.com/v2", "https://api.optimizely.com/v2", "https://api.optimizely.com/flags/v1"},
+		{"http://localhost:8080/proxy", "http://localhost:8080/proxy/v2", "http://localhost:8080/proxy/flags/v1"},
+	}
+
+	for _, c := range cases {
+		restAPI, flagsAPI, err := EndpointsFromHost(c.host)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %s", c.host, err)
+		}
+
+		if restAPI != c.restAPI || flagsAPI != c.flagsAPI {
+			t.Errorf("%s: got %s and %s, want %s and %s", c.host, restAPI, flagsAPI, c.restAPI, c.flagsAPI)
+		}
+	}
+
+	for _, host := range []string{"api.optimizely.com", "ftp://api.optimizely.com", "https://api.optimizely.com?x=1"} {
+		if _, _, err := EndpointsFromHost(host); err == nil {
+			t.Errorf("%s: expected an error", host)
+		}
+	}
+}
+
+func TestJoinURLEscapesSegments(t *testing.T) {
+	c := testClient(t, "https://api.optimizely.com")
+
+	got := c.flagsURL("projects", "123", "flags", "my flag/../x")
+	want := "https://api.optimizely.com/flags/v1/projects/123/flags/my%20flag%2F..%2Fx"
+
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/environment"
+)
+
+// flagsPageSize is the largest page the Flags API serves.
+const flagsPageSize = 100
+
+type OptimizelyEnvironment struct {
+	ID       int64  `json:"id"`
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Archived bool   `json:"archived"`
+	Priority int    `json:"priority"`
+}
+
+type listEnvironmentsResponse struct {
+	Items   []OptimizelyEnvironment `json:"items"`
+	NextURL string                  `json:"next_url"`
+}
+
+// ListEnvironmentKeys returns the keys of the project's environments that
+// aren't archived, in priority order, following the Flags API's next_url
+// through all the pages.
+func (c OptimizelyClient) ListEnvironmentKeys(ctx context.Context, projectId int) ([]string, error) {
+	var envs []OptimizelyEnvironment
+
+	query := url.Values{}
+	query.Set("per_page", strconv.Itoa(flagsPageSize))
+	pageURL := c.flagsURL("projects", strconv.Itoa(projectId), "environments") + "?" + query.Encode()
+
+	for pageURL != "" {
+		respBody, err := c.sendHttpRequest(ctx, "GET", pageURL, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var listResp listEnvironmentsResponse
+		err = json.Unmarshal(respBody, &listResp)
+		if err != nil {
+			return nil, err
+		}
+
+		envs = append(envs, listResp.Items...)
+
+		pageURL = ""
+		if listResp.NextURL != "" {
+			pageURL, err = c.flagsPageURL(listResp.NextURL)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sort.SliceStable(envs, func(i, j int) bool {
+		return envs[i].Priority < envs[j].Priority
+	})
+
+	var keys []string
+	for _, env := range envs {
+		if !env.Archived {
+			keys = append(keys, env.Key)
+		}
+	}
+
+	return keys, nil
+}
+
+// ListEnvironments returns the project's environments from the REST API,
+// which unlike the Flags API reports their datafile, reading all the pages.
+func (c OptimizelyClient) ListEnvironments(ctx context.Context, projectId int) ([]environment.Environment, error) {
+	var envs []environment.Environment
+
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("project_id", strconv.Itoa(projectId))
+		query.Set("per_page", strconv.Itoa(restPageSize))
+		query.Set("page", strconv.Itoa(page))
+
+		respBody, err := c.sendHttpRequest(ctx, "GET", c.restURL("environments")+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageResp []environment.Environment
+		err = json.Unmarshal(respBody, &pageResp)
+		if err != nil {
+			return nil, err
+		}
+
+		envs = append(envs, pageResp...)
+		if len(pageResp) < restPageSize {
+			return envs, nil
+		}
+	}
+}
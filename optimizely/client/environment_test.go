@@ -0,0 +1,75 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/internal/fakeapi"
+)
+
+func TestListEnvironmentsPages(t *testing.T) {
+	var keys []string
+	for i := 1; i <= restPageSize+5; i++ {
+		keys = append(keys, fmt.Sprintf("env%d", i))
+	}
+
+	server := fakeapi.NewServer(keys...)
+	defer server.Close()
+
+	envs, err := fakeClient(t, server).ListEnvironments(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("ListEnvironments: %s", err)
+	}
+
+	if len(envs) != restPageSize+5 {
+		t.Fatalf("expected %d environments, got %d", restPageSize+5, len(envs))
+	}
+
+	if last := envs[len(envs)-1]; last.Key != keys[len(keys)-1] {
+		t.Errorf("unexpected last environment: %+v", last)
+	}
+}
+
+func TestListEnvironmentKeysPages(t *testing.T) {
+	var keys []string
+	for i := 1; i <= flagsPageSize+5; i++ {
+		keys = append(keys, fmt.Sprintf("env%d", i))
+	}
+
+	server := fakeapi.NewServer(keys...)
+	defer server.Close()
+
+	got, err := fakeClient(t, server).ListEnvironmentKeys(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("ListEnvironmentKeys: %s", err)
+	}
+
+	if len(got) != flagsPageSize+5 {
+		t.Fatalf("expected %d environment keys, got %d", flagsPageSize+5, len(got))
+	}
+
+	if last := got[len(got)-1]; last != keys[len(keys)-1] {
+		t.Errorf("unexpected last environment: %s", last)
+	}
+}
+
+func TestFlagsPageURL(t *testing.T) {
+	c := testClient(t, "http://localhost:8080/proxy")
+
+	cases := map[string]string{
+		"/projects/1/environments?page_token=2&per_page=100":                       "http://localhost:8080/proxy/flags/v1/projects/1/environments?page_token=2&per_page=100",
+		"https://api.optimizely.com/flags/v1/projects/1/environments?page_token=2": "https://api.optimizely.com/flags/v1/projects/1/environments?page_token=2",
+	}
+
+	for next, want := range cases {
+		got, err := c.flagsPageURL(next)
+		if err != nil {
+			t.Fatalf("%s: %s", next, err)
+		}
+
+		if got != want {
+			t.Errorf("%s: got %s, want %s", next, got, want)
+		}
+	}
+}
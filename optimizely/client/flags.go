@@ -2,8 +2,10 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"sort"
+	"strconv"
 
 	"github.com/pffreitas/optimizely-terraform-provider/optimizely/flag"
 )
@@ -22,7 +24,7 @@ type OptimizelyFlagVariableDefinition struct {
 	Description  string `json:"description"`
 }
 
-func (c OptimizelyClient) CreateFlag(feat flag.Flag) (flag.Flag, error) {
+func (c OptimizelyClient) CreateFlag(ctx context.Context, feat flag.Flag) (flag.Flag, error) {
 
 	var variableDefinitions = make(map[string]OptimizelyFlagVariableDefinition)
 
@@ -46,7 +48,7 @@ func (c OptimizelyClient) CreateFlag(feat flag.Flag) (flag.Flag, error) {
 		return feat, err
 	}
 
-	respBody, err := c.sendHttpRequest("POST", fmt.Sprintf("flags/v1/projects/%d/flags", feat.ProjectId), bytes.NewBuffer(postBody))
+	respBody, err := c.sendHttpRequest(ctx, "POST", c.flagsURL("projects", strconv.Itoa(feat.ProjectId), "flags"), bytes.NewBuffer(postBody))
 	if err != nil {
 		return feat, err
 	}
@@ -57,8 +59,8 @@ func (c OptimizelyClient) CreateFlag(feat flag.Flag) (flag.Flag, error) {
 	return featureResp, nil
 }
 
-func (c OptimizelyClient) GetFlag(projectId int, flagKey string) (flag.Flag, error) {
-	respBody, err := c.sendHttpRequest("GET", fmt.Sprintf("flags/v1/projects/%d/flags/%s", projectId, flagKey), nil)
+func (c OptimizelyClient) GetFlag(ctx context.Context, projectId int, flagKey string) (flag.Flag, error) {
+	respBody, err := c.sendHttpRequest(ctx, "GET", c.flagsURL("projects", strconv.Itoa(projectId), "flags", flagKey), nil)
 	if err != nil {
 		return flag.Flag{}, err
 	}
@@ -69,7 +71,83 @@ func (c OptimizelyClient) GetFlag(projectId int, flagKey string) (flag.Flag, err
 	return flagResp, nil
 }
 
-func (c OptimizelyClient) DeleteFlag(projectId int, flagKey string) error {
-	_, err := c.sendHttpRequest("DELETE", fmt.Sprintf("flags/v1/projects/%d/flags/%s", projectId, flagKey), nil)
+// UpdateFlag patches the name, description and variable definitions of a
+// flag from oldFlag to newFlag, without sending anything when they match.
+func (c OptimizelyClient) UpdateFlag(ctx context.Context, oldFlag, newFlag flag.Flag) error {
+	ops := []OptimizelyOp{}
+
+	if oldFlag.Name != newFlag.Name {
+		ops = append(ops, OptimizelyOp{Op: OpReplace, Path: jsonPointer("name"), Value: newFlag.Name})
+	}
+
+	if oldFlag.Description != newFlag.Description {
+		ops = append(ops, OptimizelyOp{Op: OpReplace, Path: jsonPointer("description"), Value: newFlag.Description})
+	}
+
+	for _, key := range variableKeys(oldFlag.Variables, newFlag.Variables) {
+		oldVariable, inOld := oldFlag.Variables[key]
+		newVariable, inNew := newFlag.Variables[key]
+		path := jsonPointer("variable_definitions", key)
+
+		definition := OptimizelyFlagVariableDefinition{
+			Key:          key,
+			Type:         newVariable.Type,
+			DefaultValue: newVariable.DefaultValue,
+		}
+
+		switch {
+		case !inNew:
+			ops = append(ops, OptimizelyOp{Op: OpRemove, Path: path})
+		case !inOld:
+			ops = append(ops, OptimizelyOp{Op: OpAdd, Path: path, Value: definition})
+		case oldVariable != newVariable:
+			ops = append(ops, OptimizelyOp{Op: OpReplace, Path: path, Value: definition})
+		}
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	patchBody, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.sendHttpRequest(ctx, "PATCH", c.flagsURL("projects", strconv.Itoa(newFlag.ProjectId), "flags", newFlag.Key), bytes.NewBuffer(patchBody))
+	return err
+}
+
+func variableKeys(maps ...map[string]flag.VariableSchema) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range maps {
+		for key := range m {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (c OptimizelyClient) DeleteFlag(ctx context.Context, projectId int, flagKey string) error {
+	_, err := c.sendHttpRequest(ctx, "DELETE", c.flagsURL("projects", strconv.Itoa(projectId), "flags", flagKey), nil)
+	return err
+}
+
+// ArchiveFlag archives a flag, it keeps its history and can be restored
+// from the Optimizely UI.
+func (c OptimizelyClient) ArchiveFlag(ctx context.Context, projectId int, flagKey string) error {
+	postBody, err := json.Marshal(map[string]interface{}{
+		"keys": []string{flagKey},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.sendHttpRequest(ctx, "POST", c.flagsURL("projects", strconv.Itoa(projectId), "flags", "archived"), bytes.NewBuffer(postBody))
 	return err
 }
@@ -0,0 +1,235 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/apierror"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/flag"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/internal/fakeapi"
+)
+
+func fakeClient(t *testing.T, server *fakeapi.Server) OptimizelyClient {
+	c := testClient(t, server.URL)
+	c.Token = server.Token
+	return c
+}
+
+func TestFlagLifecycle(t *testing.T) {
+	server := fakeapi.NewServer("sit", "prod")
+	defer server.Close()
+
+	ctx := context.Background()
+	c := fakeClient(t, server)
+
+	feat := flag.Flag{
+		ProjectId: 1,
+		Key:       "checkout",
+		Name:      "Checkout",
+		Variables: map[string]flag.VariableSchema{
+			"color": {Key: "color", Type: "string", DefaultValue: "black"},
+		},
+		Environments: map[string]flag.FeatureEnvironment{
+			"sit": {
+				RolloutRules: []flag.RolloutRule{
+					{Key: "everyone", AudienceConditions: []flag.Condition{"and"}, PercentageIncluded: 5000, Deliver: "on"},
+				},
+			},
+		},
+	}
+
+	created, err := c.CreateFlag(ctx, feat)
+	if err != nil {
+		t.Fatalf("CreateFlag: %s", err)
+	}
+
+	if created.ID == 0 || created.Key != feat.Key {
+		t.Errorf("unexpected flag: %+v", created)
+	}
+
+	if err := c.CreateVariation(ctx, feat, flag.Variation{Key: "red", Name: "Red", Variables: map[string]interface{}{"color": "red"}}); err != nil {
+		t.Fatalf("CreateVariation: %s", err)
+	}
+
+	if err := c.CreateRuleset(ctx, feat); err != nil {
+		t.Fatalf("CreateRuleset: %s", err)
+	}
+
+	if err := c.EnableRuleset(ctx, feat); err != nil {
+		t.Fatalf("EnableRuleset: %s", err)
+	}
+
+	envs, err := c.GetRuleset(ctx, feat)
+	if err != nil {
+		t.Fatalf("GetRuleset: %s", err)
+	}
+
+	rules := envs["sit"].RolloutRules
+	if len(rules) != 1 || rules[0].Key != "everyone" || rules[0].PercentageIncluded != 50 || rules[0].Deliver != "on" {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+
+	if err := c.DeleteFlag(ctx, feat.ProjectId, feat.Key); !apierror.IsConflict(err) {
+		t.Errorf("expected deleting an enabled flag to conflict, got %v", err)
+	}
+
+	if err := c.DisableRuleset(ctx, feat); err != nil {
+		t.Fatalf("DisableRuleset: %s", err)
+	}
+
+	if err := c.DeleteFlag(ctx, feat.ProjectId, feat.Key); err != nil {
+		t.Fatalf("DeleteFlag: %s", err)
+	}
+
+	if _, err := c.GetFlag(ctx, feat.ProjectId, feat.Key); !apierror.IsNotFound(err) {
+		t.Errorf("expected a deleted flag to be not found, got %v", err)
+	}
+}
+
+func TestRemoveVariableInUse(t *testing.T) {
+	server := fakeapi.NewServer("sit")
+	defer server.Close()
+
+	ctx := context.Background()
+	c := fakeClient(t, server)
+
+	oldFlag := flag.Flag{
+		ProjectId: 1,
+		Key:       "checkout",
+		Variables: map[string]flag.VariableSchema{
+			"color": {Key: "color", Type: "string", DefaultValue: "black"},
+		},
+	}
+	red := flag.Variation{Key: "red", Name: "Red", Variables: map[string]interface{}{"color": "red"}}
+
+	if _, err := c.CreateFlag(ctx, oldFlag); err != nil {
+		t.Fatalf("CreateFlag: %s", err)
+	}
+
+	if err := c.CreateVariation(ctx, oldFlag, red); err != nil {
+		t.Fatalf("CreateVariation: %s", err)
+	}
+
+	newFlag := oldFlag
+	newFlag.Variables = map[string]flag.VariableSchema{}
+
+	if err := c.UpdateFlag(ctx, oldFlag, newFlag); err == nil {
+		t.Fatal("expected removing a variable used by a variation to fail")
+	}
+
+	if err := c.UpdateVariation(ctx, newFlag, red, flag.Variation{Key: "red", Name: "Red"}); err != nil {
+		t.Fatalf("UpdateVariation: %s", err)
+	}
+
+	if err := c.UpdateFlag(ctx, oldFlag, newFlag); err != nil {
+		t.Fatalf("UpdateFlag: %s", err)
+	}
+}
+
+func TestUpdateRuleset(t *testing.T) {
+	server := fakeapi.NewServer("sit", "prod")
+	defer server.Close()
+
+	ctx := context.Background()
+	c := fakeClient(t, server)
+
+	rule := func(key string, percentage int) flag.RolloutRule {
+		return flag.RolloutRule{Key: key, AudienceConditions: []flag.Condition{"and"}, PercentageIncluded: percentage, Deliver: "on"}
+	}
+
+	oldFlag := flag.Flag{
+		ProjectId: 1,
+		Key:       "checkout",
+		Environments: map[string]flag.FeatureEnvironment{
+			"sit":  {RolloutRules: []flag.RolloutRule{rule("a", 1000), rule("b", 2000)}},
+			"prod": {RolloutRules: []flag.RolloutRule{rule("p", 3000)}},
+		},
+	}
+
+	if _, err := c.CreateFlag(ctx, oldFlag); err != nil {
+		t.Fatalf("CreateFlag: %s", err)
+	}
+
+	if err := c.CreateRuleset(ctx, oldFlag); err != nil {
+		t.Fatalf("CreateRuleset: %s", err)
+	}
+
+	// b is removed, a changes, c is added ahead of it and prod is dropped
+	newFlag := oldFlag
+	newFlag.Environments = map[string]flag.FeatureEnvironment{
+		"sit": {RolloutRules: []flag.RolloutRule{rule("c", 4000), rule("a", 5000)}},
+	}
+
+	if err := c.UpdateRuleset(ctx, oldFlag, newFlag); err != nil {
+		t.Fatalf("UpdateRuleset: %s", err)
+	}
+
+	sit := server.Ruleset(1, "checkout", "sit")
+	rules := sit["rules"].(map[string]interface{})
+	if len(rules) != 2 || rules["a"] == nil || rules["c"] == nil {
+		t.Errorf("expected rules a and c in sit, got %v", rules)
+	}
+
+	if percentage := rules["a"].(map[string]interface{})["percentage_included"]; percentage != float64(5000) {
+		t.Errorf("expected rule a to be replaced, got percentage_included %v", percentage)
+	}
+
+	if priorities := fmt.Sprint(sit["rule_priorities"]); priorities != "[c a]" {
+		t.Errorf("expected sit priorities [c a], got %s", priorities)
+	}
+
+	prod := server.Ruleset(1, "checkout", "prod")
+	if rules := prod["rules"].(map[string]interface{}); len(rules) != 0 {
+		t.Errorf("expected prod rules to be removed, got %v", rules)
+	}
+
+	if err := c.UpdateRuleset(ctx, newFlag, newFlag); err != nil {
+		t.Fatalf("UpdateRuleset without changes: %s", err)
+	}
+}
+
+func TestRulesetAudienceConditions(t *testing.T) {
+	server := fakeapi.NewServer("sit")
+	defer server.Close()
+
+	ctx := context.Background()
+	c := fakeClient(t, server)
+
+	// US or Canada, but not employees
+	conditions := []flag.Condition{
+		"and",
+		[]flag.Condition{"or", flag.AudienceCondition{AudienceID: 1}, flag.AudienceCondition{AudienceID: 2}},
+		[]flag.Condition{"not", flag.AudienceCondition{AudienceID: 3}},
+	}
+
+	feat := flag.Flag{
+		ProjectId: 1,
+		Key:       "checkout",
+		Environments: map[string]flag.FeatureEnvironment{
+			"sit": {
+				RolloutRules: []flag.RolloutRule{
+					{Key: "north-america", AudienceConditions: conditions, PercentageIncluded: 10000, Deliver: "on"},
+				},
+			},
+		},
+	}
+
+	if _, err := c.CreateFlag(ctx, feat); err != nil {
+		t.Fatalf("CreateFlag: %s", err)
+	}
+
+	if err := c.CreateRuleset(ctx, feat); err != nil {
+		t.Fatalf("CreateRuleset: %s", err)
+	}
+
+	envs, err := c.GetRuleset(ctx, feat)
+	if err != nil {
+		t.Fatalf("GetRuleset: %s", err)
+	}
+
+	if got := envs["sit"].RolloutRules[0].AudienceConditions; !reflect.DeepEqual(got, conditions) {
+		t.Errorf("expected conditions %v, got %v", conditions, got)
+	}
+}
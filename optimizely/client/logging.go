@@ -0,0 +1,39 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+)
+
+// logSubsystem groups API request logs, its level can be set on its own
+// with TF_LOG_PROVIDER_OPTIMIZELY_API.
+const logSubsystem = "api"
+
+const redacted = "***"
+
+func (c OptimizelyClient) logContext(ctx context.Context) context.Context {
+	ctx = tflog.NewSubsystem(ctx, logSubsystem, tflog.WithLevelFromEnv("TF_LOG_PROVIDER_OPTIMIZELY", strings.ToUpper(logSubsystem)))
+	ctx = tflog.SubsystemMaskFieldValuesWithFieldKeys(ctx, logSubsystem, "authorization")
+
+	if c.Token != "" {
+		ctx = tflog.SubsystemMaskLogStrings(ctx, logSubsystem, c.Token)
+	}
+
+	return ctx
+}
+
+// redactHeaders copies headers for logging, hiding credentials.
+func redactHeaders(headers http.Header) map[string]string {
+	redactedHeaders := make(map[string]string, len(headers))
+	for name, values := range headers {
+		if strings.EqualFold(name, "Authorization") {
+			redactedHeaders[name] = redacted
+			continue
+		}
+		redactedHeaders[name] = strings.Join(values, ", ")
+	}
+	return redactedHeaders
+}
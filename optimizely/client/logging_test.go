@@ -0,0 +1,56 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-log/tflogtest"
+)
+
+func TestSendHttpRequestLogsWithoutSecrets(t *testing.T) {
+	const token = "s3cr3t-t0k3n"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"echo": "` + r.Header.Get("Authorization") + `"}`))
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	ctx := tflogtest.RootLogger(context.Background(), &output)
+
+	c := testClient(t, server.URL)
+	c.Token = token
+
+	if _, err := c.sendHttpRequest(ctx, "POST", c.restURL("audiences"), strings.NewReader(`{"name": "`+token+`"}`)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	logs := output.String()
+	if strings.Contains(logs, token) {
+		t.Errorf("token leaked into logs:\n%s", logs)
+	}
+
+	entries, err := tflogtest.MultilineJSONDecode(&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) == 0 {
+		t.Fatalf("expected API requests to be logged")
+	}
+
+	found := false
+	for _, entry := range entries {
+		if entry["@message"] == "Received Optimizely API response" {
+			found = entry["status"] == float64(200) && entry["method"] == "POST" && entry["latency_ms"] != nil
+		}
+	}
+
+	if !found {
+		t.Errorf("expected a response entry with method, status and latency, got %+v", entries)
+	}
+}
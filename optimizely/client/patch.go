@@ -0,0 +1,16 @@
+package client
+
+import "strings"
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// jsonPointer builds the RFC 6901 pointer to the given tokens, escaping the
+// keys of flags, variables and rules that contain "~" or "/".
+func jsonPointer(tokens ...string) string {
+	var pointer strings.Builder
+	for _, token := range tokens {
+		pointer.WriteString("/")
+		pointer.WriteString(pointerEscaper.Replace(token))
+	}
+	return pointer.String()
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"strconv"
+
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/project"
+)
+
+// restPageSize is the largest page the REST API serves.
+const restPageSize = 100
+
+func (c OptimizelyClient) GetProject(ctx context.Context, projectId int64) (project.Project, error) {
+	respBody, err := c.sendHttpRequest(ctx, "GET", c.restURL("projects", strconv.FormatInt(projectId, 10)), nil)
+	if err != nil {
+		return project.Project{}, err
+	}
+
+	var projectResp project.Project
+	err = json.Unmarshal(respBody, &projectResp)
+	if err != nil {
+		return project.Project{}, err
+	}
+
+	return projectResp, nil
+}
+
+// ListProjects returns every project of the account, reading all the pages.
+func (c OptimizelyClient) ListProjects(ctx context.Context) ([]project.Project, error) {
+	var projects []project.Project
+
+	for page := 1; ; page++ {
+		query := url.Values{}
+		query.Set("per_page", strconv.Itoa(restPageSize))
+		query.Set("page", strconv.Itoa(page))
+
+		respBody, err := c.sendHttpRequest(ctx, "GET", c.restURL("projects")+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var pageResp []project.Project
+		err = json.Unmarshal(respBody, &pageResp)
+		if err != nil {
+			return nil, err
+		}
+
+		projects = append(projects, pageResp...)
+		if len(pageResp) < restPageSize {
+			return projects, nil
+		}
+	}
+}
+
+// ListSDKKeys returns the SDK key of the project's environments that aren't
+// archived, by environment key.
+func (c OptimizelyClient) ListSDKKeys(ctx context.Context, projectId int64) (map[string]string, error) {
+	envs, err := c.ListEnvironments(ctx, int(projectId))
+	if err != nil {
+		return nil, err
+	}
+
+	sdkKeys := map[string]string{}
+	for _, env := range envs {
+		if !env.Archived {
+			sdkKeys[env.Key] = env.Datafile.SDKKey
+		}
+	}
+
+	return sdkKeys, nil
+}
+
+func (c OptimizelyClient) CreateProject(ctx context.Context, proj project.Project) (project.Project, error) {
+	postBody, err := json.Marshal(proj)
+	if err != nil {
+		return project.Project{}, err
+	}
+
+	respBody, err := c.sendHttpRequest(ctx, "POST", c.restURL("projects"), bytes.NewBuffer(postBody))
+	if err != nil {
+		return project.Project{}, err
+	}
+
+	var projectResp project.Project
+	err = json.Unmarshal(respBody, &projectResp)
+	if err != nil {
+		return project.Project{}, err
+	}
+
+	return projectResp, nil
+}
+
+// UpdateProject patches the name, description and status of a project, the
+// only fields that can change after it's created.
+func (c OptimizelyClient) UpdateProject(ctx context.Context, proj project.Project) (project.Project, error) {
+	return c.patchProject(ctx, proj.ID, map[string]interface{}{
+		"name":        proj.Name,
+		"description": proj.Description,
+		"status":      proj.Status,
+	})
+}
+
+// ArchiveProject archives a project, Optimizely never deletes them.
+func (c OptimizelyClient) ArchiveProject(ctx context.Context, projectId int64) (project.Project, error) {
+	return c.patchProject(ctx, projectId, map[string]interface{}{
+		"status": project.StatusArchived,
+	})
+}
+
+func (c OptimizelyClient) patchProject(ctx context.Context, projectId int64, fields map[string]interface{}) (project.Project, error) {
+	patchBody, err := json.Marshal(fields)
+	if err != nil {
+		return project.Project{}, err
+	}
+
+	respBody, err := c.sendHttpRequest(ctx, "PATCH", c.restURL("projects", strconv.FormatInt(projectId, 10)), bytes.NewBuffer(patchBody))
+	if err != nil {
+		return project.Project{}, err
+	}
+
+	var projectResp project.Project
+	err = json.Unmarshal(respBody, &projectResp)
+	if err != nil {
+		return project.Project{}, err
+	}
+
+	return projectResp, nil
+}
@@ -0,0 +1,43 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/internal/fakeapi"
+)
+
+func TestListProjectsPages(t *testing.T) {
+	server := fakeapi.NewServer("sit", "prod")
+	defer server.Close()
+
+	for id := int64(1); id <= restPageSize+5; id++ {
+		server.AddProject(id, fmt.Sprintf("project %d", id))
+	}
+
+	ctx := context.Background()
+	c := fakeClient(t, server)
+
+	projects, err := c.ListProjects(ctx)
+	if err != nil {
+		t.Fatalf("ListProjects: %s", err)
+	}
+
+	if len(projects) != restPageSize+5 {
+		t.Fatalf("expected %d projects, got %d", restPageSize+5, len(projects))
+	}
+
+	if last := projects[len(projects)-1]; last.ID != restPageSize+5 || last.Name != fmt.Sprintf("project %d", last.ID) {
+		t.Errorf("unexpected last project: %+v", last)
+	}
+
+	sdkKeys, err := c.ListSDKKeys(ctx, 1)
+	if err != nil {
+		t.Fatalf("ListSDKKeys: %s", err)
+	}
+
+	if len(sdkKeys) != 2 || sdkKeys["sit"] == "" || sdkKeys["prod"] == "" {
+		t.Errorf("unexpected SDK keys: %v", sdkKeys)
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultMaxRetries   = 5
+	DefaultRetryMaxWait = 30 * time.Second
+
+	retryMinWait = 1 * time.Second
+)
+
+// idempotentMethods can be sent again without risk of applying a change twice.
+var idempotentMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodOptions: true,
+	http.MethodPut:     true,
+	http.MethodDelete:  true,
+}
+
+// shouldRetry reports whether a request is worth sending again. A 429 means
+// Optimizely rejected the request before processing it, so it is safe to
+// retry for any method; transport errors and 5xx responses are only retried
+// for idempotent methods, since a POST or PATCH may already have been applied.
+func shouldRetry(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		return idempotentMethods[method]
+	}
+
+	if resp == nil {
+		return false
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotentMethods[method]
+	}
+
+	return false
+}
+
+// retryBackoff returns how long to wait before the next attempt. The
+// Retry-After header wins when present; otherwise a jittered exponential
+// backoff is used. Either way the wait never exceeds maxWait.
+func retryBackoff(attempt int, resp *http.Response, maxWait time.Duration) time.Duration {
+	if maxWait <= 0 {
+		maxWait = DefaultRetryMaxWait
+	}
+
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if wait > maxWait {
+				return maxWait
+			}
+			return wait
+		}
+	}
+
+	backoff := float64(retryMinWait) * math.Pow(2, float64(attempt))
+	if backoff > float64(maxWait) {
+		backoff = float64(maxWait)
+	}
+
+	// full jitter: spread concurrent resources across the whole window so
+	// they don't hit the rate limit again in lockstep
+	return time.Duration(rand.Int63n(int64(backoff)) + 1)
+}
+
+// parseRetryAfter understands both forms allowed by RFC 7231: a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// sleepContext waits for the given duration unless ctx is done first, so a
+// cancelled apply doesn't sit out a long backoff.
+func sleepContext(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,105 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShouldRetry(t *testing.T) {
+	cases := []struct {
+		method string
+		status int
+		want   bool
+	}{
+		{http.MethodGet, http.StatusTooManyRequests, true},
+		{http.MethodPost, http.StatusTooManyRequests, true},
+		{http.MethodGet, http.StatusServiceUnavailable, true},
+		{http.MethodDelete, http.StatusBadGateway, true},
+		{http.MethodPost, http.StatusServiceUnavailable, false},
+		{http.MethodPatch, http.StatusInternalServerError, false},
+		{http.MethodGet, http.StatusNotFound, false},
+		{http.MethodGet, http.StatusOK, false},
+	}
+
+	for _, c := range cases {
+		got := shouldRetry(c.method, &http.Response{StatusCode: c.status}, nil)
+		if got != c.want {
+			t.Errorf("shouldRetry(%s, %d) = %v, want %v", c.method, c.status, got, c.want)
+		}
+	}
+}
+
+func TestRetryBackoffHonorsRetryAfter(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"2"}}}
+	if wait := retryBackoff(0, resp, time.Minute); wait != 2*time.Second {
+		t.Errorf("expected Retry-After wait of 2s, got %s", wait)
+	}
+
+	if wait := retryBackoff(0, resp, time.Second); wait != time.Second {
+		t.Errorf("expected Retry-After wait to be capped at 1s, got %s", wait)
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		if wait := retryBackoff(attempt, nil, 5*time.Second); wait <= 0 || wait > 5*time.Second {
+			t.Errorf("attempt %d: backoff %s outside (0, 5s]", attempt, wait)
+		}
+	}
+}
+
+func TestSendHttpRequestRetriesRateLimitedRequests(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"id": 1}`))
+	}))
+	defer server.Close()
+
+	c := testClient(t, server.URL)
+	c.MaxRetries, c.RetryMaxWait = 3, time.Second
+	body, err := c.sendHttpRequest(context.Background(), "POST", c.restURL("audiences"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if string(body) != `{"id": 1}` || calls != 3 {
+		t.Errorf("expected success after 3 calls, got %q after %d calls", body, calls)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	c.MaxRetries = 1
+	if _, err := c.sendHttpRequest(context.Background(), "GET", c.restURL("audiences", "1"), nil); err == nil {
+		t.Errorf("expected an error once retries are exhausted")
+	}
+}
+
+func TestSendHttpRequestStopsRetryingWhenContextIsCancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	c := testClient(t, server.URL)
+	c.MaxRetries, c.RetryMaxWait = 5, time.Minute
+
+	start := time.Now()
+	_, err := c.sendHttpRequest(ctx, "GET", c.restURL("audiences", "1"), nil)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request kept waiting %s after the context deadline", elapsed)
+	}
+}
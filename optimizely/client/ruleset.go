@@ -2,8 +2,11 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
 
 	"github.com/pffreitas/optimizely-terraform-provider/optimizely/flag"
 )
@@ -27,6 +30,12 @@ type RulesetVariation struct {
 type AudicenceCondition struct {
 }
 
+type RulesetMetric struct {
+	EventID          int64  `json:"event_id"`
+	Aggregator       string `json:"aggregator"`
+	WinningDirection string `json:"winning_direction"`
+}
+
 type OptimizelyRuleset struct {
 	Key                 string                      `json:"key"`
 	Name                string                      `json:"name"`
@@ -34,10 +43,18 @@ type OptimizelyRuleset struct {
 	PercentageIncluded  int                         `json:"percentage_included"`
 	Variations          map[string]RulesetVariation `json:"variations"`
 	AudicenceConditions []flag.Condition            `json:"audience_conditions"`
+	Metrics             []RulesetMetric             `json:"metrics,omitempty"`
+	DistributionMode    string                      `json:"distribution_mode,omitempty"`
 }
 
 type Operation string
 
+const (
+	OpAdd     Operation = "add"
+	OpReplace Operation = "replace"
+	OpRemove  Operation = "remove"
+)
+
 type OptimizelyOp struct {
 	Op    Operation   `json:"op"`
 	Path  string      `json:"path"`
@@ -49,46 +66,123 @@ type OptimizelyRules struct {
 	RulePriorities []string            `json:"rule_priorities"`
 }
 
-func (c OptimizelyClient) PatchRuleset(flag flag.Flag, operation Operation) error {
-	for env, flagEnv := range flag.Environments {
-		ops := []OptimizelyOp{}
-
-		for i, rule := range flagEnv.RolloutRules {
-
-			ruleset := OptimizelyRuleset{
-				Key:  rule.Key,
-				Name: rule.Key,
-				Type: TargetedDelivery,
-				Variations: map[string]RulesetVariation{
-					rule.Deliver: {
-						Key:                rule.Deliver,
-						PercentageIncluded: 10000,
-					},
-				},
-				AudicenceConditions: rule.AudienceConditions,
-				PercentageIncluded:  rule.PercentageIncluded,
-			}
+// newRuleset builds the Optimizely rule for a rollout rule: a targeted
+// delivery serves its one variation to all the traffic it includes, an A/B
+// test splits it between its variations by weight.
+func newRuleset(rule flag.RolloutRule) OptimizelyRuleset {
+	ruleset := OptimizelyRuleset{
+		Key:                 rule.Key,
+		Name:                rule.Key,
+		Type:                TargetedDelivery,
+		AudicenceConditions: rule.AudienceConditions,
+		PercentageIncluded:  rule.PercentageIncluded,
+	}
+
+	if rule.Type != string(ABTesting) {
+		ruleset.Variations = map[string]RulesetVariation{
+			rule.Deliver: {
+				Key:                rule.Deliver,
+				PercentageIncluded: 10000,
+			},
+		}
+		return ruleset
+	}
+
+	ruleset.Type = ABTesting
+	ruleset.DistributionMode = rule.DistributionMode
+	ruleset.Variations = make(map[string]RulesetVariation)
+	for _, variation := range rule.Variations {
+		ruleset.Variations[variation.Key] = RulesetVariation{
+			Key:                variation.Key,
+			PercentageIncluded: variation.Weight,
+		}
+	}
+
+	ruleset.Metrics = []RulesetMetric{}
+	for _, metric := range rule.Metrics {
+		ruleset.Metrics = append(ruleset.Metrics, RulesetMetric{
+			EventID:          metric.EventID,
+			Aggregator:       metric.Aggregator,
+			WinningDirection: metric.WinningDirection,
+		})
+	}
+
+	return ruleset
+}
+
+// rulesetPatch returns the operations turning a ruleset with oldRules into
+// one with newRules: rules are added, replaced or removed by key, and
+// rule_priorities is rewritten whenever the keys or their order change.
+func rulesetPatch(oldRules, newRules []flag.RolloutRule) []OptimizelyOp {
+	ops := []OptimizelyOp{}
+
+	oldRulesets := make(map[string]OptimizelyRuleset)
+	oldPriorities := []string{}
+	for _, rule := range oldRules {
+		oldRulesets[rule.Key] = newRuleset(rule)
+		oldPriorities = append(oldPriorities, rule.Key)
+	}
+
+	newPriorities := []string{}
+	for _, rule := range newRules {
+		ruleset := newRuleset(rule)
+		newPriorities = append(newPriorities, rule.Key)
+
+		oldRuleset, ok := oldRulesets[rule.Key]
+		delete(oldRulesets, rule.Key)
+
+		switch {
+		case !ok:
+			ops = append(ops, OptimizelyOp{Op: OpAdd, Path: jsonPointer("rules", rule.Key), Value: ruleset})
+		case !reflect.DeepEqual(oldRuleset, ruleset):
+			ops = append(ops, OptimizelyOp{Op: OpReplace, Path: jsonPointer("rules", rule.Key), Value: ruleset})
+		}
+	}
+
+	removed := make([]string, 0, len(oldRulesets))
+	for key := range oldRulesets {
+		removed = append(removed, key)
+	}
+	sort.Strings(removed)
+
+	for _, key := range removed {
+		ops = append(ops, OptimizelyOp{Op: OpRemove, Path: jsonPointer("rules", key)})
+	}
+
+	if !reflect.DeepEqual(oldPriorities, newPriorities) {
+		ops = append(ops, OptimizelyOp{Op: OpReplace, Path: jsonPointer("rule_priorities"), Value: newPriorities})
+	}
 
-			ops = append(ops, OptimizelyOp{
-				Op:    operation,
-				Path:  fmt.Sprintf("/rules/%s", rule.Key),
-				Value: ruleset,
-			})
+	return ops
+}
 
-			ops = append(ops, OptimizelyOp{
-				Op:    operation,
-				Path:  fmt.Sprintf("/rule_priorities/%d", i),
-				Value: rule.Key,
-			})
+// PatchRuleset patches the ruleset of every environment of oldFlag or
+// newFlag from the rules of oldFlag to the ones of newFlag, so environments
+// dropped from newFlag lose their rules.
+func (c OptimizelyClient) PatchRuleset(ctx context.Context, oldFlag, newFlag flag.Flag) error {
+	var envs []string
+	for env := range oldFlag.Environments {
+		envs = append(envs, env)
+	}
+	for env := range newFlag.Environments {
+		if _, ok := oldFlag.Environments[env]; !ok {
+			envs = append(envs, env)
+		}
+	}
+	sort.Strings(envs)
 
+	for _, env := range envs {
+		ops := rulesetPatch(oldFlag.Environments[env].RolloutRules, newFlag.Environments[env].RolloutRules)
+		if len(ops) == 0 {
+			continue
 		}
 
-		postBody, err := json.Marshal(ops)
+		patchBody, err := json.Marshal(ops)
 		if err != nil {
 			return err
 		}
 
-		_, err = c.sendHttpRequest("PATCH", fmt.Sprintf("flags/v1/projects/%d/flags/%s/environments/%s/ruleset", flag.ProjectId, flag.Key, env), bytes.NewBuffer(postBody))
+		_, err = c.sendHttpRequest(ctx, "PATCH", c.flagsURL("projects", strconv.Itoa(newFlag.ProjectId), "flags", newFlag.Key, "environments", env, "ruleset"), bytes.NewBuffer(patchBody))
 		if err != nil {
 			return err
 		}
@@ -96,25 +190,76 @@ func (c OptimizelyClient) PatchRuleset(flag flag.Flag, operation Operation) erro
 	return nil
 }
 
-func (c OptimizelyClient) CreateRuleset(flag flag.Flag) error {
-	return c.PatchRuleset(flag, "add")
+func (c OptimizelyClient) CreateRuleset(ctx context.Context, flg flag.Flag) error {
+	return c.PatchRuleset(ctx, flag.Flag{ProjectId: flg.ProjectId, Key: flg.Key}, flg)
 }
 
-func (c OptimizelyClient) UpdateRuleset(flag flag.Flag) error {
-	return c.PatchRuleset(flag, "replace")
+func (c OptimizelyClient) UpdateRuleset(ctx context.Context, oldFlag, newFlag flag.Flag) error {
+	return c.PatchRuleset(ctx, oldFlag, newFlag)
 }
 
 type getRulesetResponse struct {
-	Rules map[string]OptimizelyRuleset `json:"rules"`
+	Enabled        bool                         `json:"enabled"`
+	Rules          map[string]OptimizelyRuleset `json:"rules"`
+	RulePriorities []string                     `json:"rule_priorities"`
+}
+
+// orderedRules returns the rules of a ruleset in priority order.
+func (r getRulesetResponse) orderedRules() []OptimizelyRuleset {
+	rules := make([]OptimizelyRuleset, 0, len(r.Rules))
+	seen := make(map[string]bool)
+
+	for _, key := range r.RulePriorities {
+		if rule, ok := r.Rules[key]; ok && !seen[key] {
+			rules = append(rules, rule)
+			seen[key] = true
+		}
+	}
+
+	var unprioritized []string
+	for key := range r.Rules {
+		if !seen[key] {
+			unprioritized = append(unprioritized, key)
+		}
+	}
+	sort.Strings(unprioritized)
+
+	for _, key := range unprioritized {
+		rules = append(rules, r.Rules[key])
+	}
+
+	return rules
 }
 
-func (c OptimizelyClient) GetRuleset(flg flag.Flag) (map[string]flag.FeatureEnvironment, error) {
+// parseConditions turns audience conditions decoded from JSON back into
+// operators, audience references and nested condition arrays.
+func parseConditions(raw []flag.Condition) []flag.Condition {
+	conditions := []flag.Condition{}
+	for _, cond := range raw {
+		switch c := cond.(type) {
+		case string:
+			conditions = append(conditions, c)
+		case map[string]interface{}:
+			if audienceId, ok := c["audience_id"].(float64); ok {
+				conditions = append(conditions, flag.AudienceCondition{AudienceID: int64(audienceId)})
+			}
+		case []interface{}:
+			nested := make([]flag.Condition, len(c))
+			for i, item := range c {
+				nested[i] = item
+			}
+			conditions = append(conditions, parseConditions(nested))
+		}
+	}
+	return conditions
+}
+
+func (c OptimizelyClient) GetRuleset(ctx context.Context, flg flag.Flag) (map[string]flag.FeatureEnvironment, error) {
 	flagEnvs := make(map[string]flag.FeatureEnvironment)
 
 	for env := range flg.Environments {
-		flagEnv := flag.FeatureEnvironment{}
 
-		rulesetResponseBodyStr, err := c.sendHttpRequest("GET", fmt.Sprintf("flags/v1/projects/%d/flags/%s/environments/%s/ruleset", flg.ProjectId, flg.Key, env), nil)
+		rulesetResponseBodyStr, err := c.sendHttpRequest(ctx, "GET", c.flagsURL("projects", strconv.Itoa(flg.ProjectId), "flags", flg.Key, "environments", env, "ruleset"), nil)
 		if err != nil {
 			return flagEnvs, err
 		}
@@ -125,30 +270,45 @@ func (c OptimizelyClient) GetRuleset(flg flag.Flag) (map[string]flag.FeatureEnvi
 			return flagEnvs, err
 		}
 
-		for _, ruleset := range rulesetResponseBody.Rules {
+		flagEnv := flag.FeatureEnvironment{
+			Enabled: rulesetResponseBody.Enabled,
+		}
+
+		for _, ruleset := range rulesetResponseBody.orderedRules() {
 
-			deliver := ""
-			for variationKey := range ruleset.Variations {
-				deliver = variationKey
+			rolloutRule := flag.RolloutRule{
+				Key:                ruleset.Key,
+				Type:               string(ruleset.Type),
+				PercentageIncluded: ruleset.PercentageIncluded / 100,
+				DistributionMode:   ruleset.DistributionMode,
 			}
 
-			audienceConditions := []flag.Condition{}
-			for _, aud := range ruleset.AudicenceConditions {
-				if audienceConditionMap, ok := aud.(map[string]interface{}); ok {
-					if audienceId, ok := audienceConditionMap["audience_id"].(float64); ok {
-						audienceConditions = append(audienceConditions, flag.AudienceCondition{
-							AudienceID: int64(audienceId),
-						})
-					}
+			if ruleset.Type == ABTesting {
+				for variationKey, variation := range ruleset.Variations {
+					rolloutRule.Variations = append(rolloutRule.Variations, flag.RuleVariation{
+						Key:    variationKey,
+						Weight: variation.PercentageIncluded / 100,
+					})
+				}
+				sort.Slice(rolloutRule.Variations, func(i, j int) bool {
+					return rolloutRule.Variations[i].Key < rolloutRule.Variations[j].Key
+				})
+
+				for _, metric := range ruleset.Metrics {
+					rolloutRule.Metrics = append(rolloutRule.Metrics, flag.Metric{
+						EventID:          metric.EventID,
+						Aggregator:       metric.Aggregator,
+						WinningDirection: metric.WinningDirection,
+					})
+				}
+			} else {
+				for variationKey := range ruleset.Variations {
+					rolloutRule.Deliver = variationKey
 				}
 			}
 
-			flagEnv.RolloutRules = append(flagEnv.RolloutRules, flag.RolloutRule{
-				Key:                ruleset.Key,
-				PercentageIncluded: ruleset.PercentageIncluded / 100,
-				AudienceConditions: audienceConditions,
-				Deliver:            deliver,
-			})
+			rolloutRule.AudienceConditions = parseConditions(ruleset.AudicenceConditions)
+			flagEnv.RolloutRules = append(flagEnv.RolloutRules, rolloutRule)
 		}
 
 		flagEnvs[env] = flagEnv
@@ -158,10 +318,10 @@ func (c OptimizelyClient) GetRuleset(flg flag.Flag) (map[string]flag.FeatureEnvi
 
 }
 
-func (c OptimizelyClient) EnableRuleset(flag flag.Flag) error {
+func (c OptimizelyClient) EnableRuleset(ctx context.Context, flag flag.Flag) error {
 
 	for env := range flag.Environments {
-		_, err := c.sendHttpRequest("POST", fmt.Sprintf("flags/v1/projects/%d/flags/%s/environments/%s/ruleset/enabled", flag.ProjectId, flag.Key, env), nil)
+		_, err := c.sendHttpRequest(ctx, "POST", c.flagsURL("projects", strconv.Itoa(flag.ProjectId), "flags", flag.Key, "environments", env, "ruleset", "enabled"), nil)
 		if err != nil {
 			return err
 		}
@@ -170,10 +330,10 @@ func (c OptimizelyClient) EnableRuleset(flag flag.Flag) error {
 	return nil
 }
 
-func (c OptimizelyClient) DisableRuleset(flag flag.Flag) error {
+func (c OptimizelyClient) DisableRuleset(ctx context.Context, flag flag.Flag) error {
 
 	for env := range flag.Environments {
-		_, err := c.sendHttpRequest("POST", fmt.Sprintf("flags/v1/projects/%d/flags/%s/environments/%s/ruleset/disabled", flag.ProjectId, flag.Key, env), nil)
+		_, err := c.sendHttpRequest(ctx, "POST", c.flagsURL("projects", strconv.Itoa(flag.ProjectId), "flags", flag.Key, "environments", env, "ruleset", "disabled"), nil)
 		if err != nil {
 			return err
 		}
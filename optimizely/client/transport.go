@@ -0,0 +1,88 @@
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const DefaultRequestTimeout = 60 * time.Second
+
+type TransportConfig struct {
+	// Timeout bounds a single HTTP attempt, retries get a fresh timeout.
+	Timeout            time.Duration
+	ProxyURL           string
+	CACertFile         string
+	InsecureSkipVerify bool
+
+	// RecordMode and Cassette turn on recording or replaying of API
+	// interactions, Secrets are scrubbed from what gets recorded.
+	RecordMode string
+	Cassette   string
+	Secrets    []string
+}
+
+// NewHTTPClient builds the http.Client shared by every request of a
+// provider instance, so connections are kept alive and reused across
+// resources.
+func NewHTTPClient(config TransportConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 16
+	transport.ForceAttemptHTTP2 = true
+
+	if config.ProxyURL != "" {
+		proxyURL, err := url.Parse(config.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy_url %q: %w", config.ProxyURL, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CACertFile != "" {
+		pem, err := ioutil.ReadFile(config.CACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_cert_file: %w", err)
+		}
+
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_cert_file %s holds no PEM encoded certificate", config.CACertFile)
+		}
+
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	timeout := config.Timeout
+	if timeout <= 0 {
+		timeout = DefaultRequestTimeout
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if config.RecordMode != "" {
+		cassetteTransport, err := newCassetteTransport(config.RecordMode, config.Cassette, transport, config.Secrets)
+		if err != nil {
+			return nil, err
+		}
+		roundTripper = cassetteTransport
+	}
+
+	return &http.Client{
+		Transport: roundTripper,
+		Timeout:   timeout,
+	}, nil
+}
@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewHTTPClientTrustsCACertFile(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("User-Agent")))
+	}))
+	defer server.Close()
+
+	caCertFile := filepath.Join(t.TempDir(), "ca.pem")
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := ioutil.WriteFile(caCertFile, caCert, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	untrusted, err := NewHTTPClient(TransportConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := untrusted.Get(server.URL); err == nil {
+		t.Errorf("expected the test server certificate to be rejected without ca_cert_file")
+	}
+
+	httpClient, err := NewHTTPClient(TransportConfig{CACertFile: caCertFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := testClient(t, server.URL)
+	c.HTTPClient = httpClient
+	c.UserAgent = "terraform-provider-optimizely/test"
+
+	body, err := c.sendHttpRequest(context.Background(), "GET", c.restURL("projects"), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if string(body) != c.UserAgent {
+		t.Errorf("expected User-Agent %q, got %q", c.UserAgent, body)
+	}
+}
@@ -2,8 +2,10 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"sort"
+	"strconv"
 
 	"github.com/pffreitas/optimizely-terraform-provider/optimizely/flag"
 )
@@ -17,7 +19,7 @@ type OptimizelyVariationVariable struct {
 	Value interface{} `json:"value"`
 }
 
-func (c OptimizelyClient) CreateVariation(flag flag.Flag, variation flag.Variation) error {
+func (c OptimizelyClient) CreateVariation(ctx context.Context, flag flag.Flag, variation flag.Variation) error {
 
 	optVariationVariables := make(map[string]OptimizelyVariationVariable)
 	for key, value := range variation.Variables {
@@ -34,7 +36,7 @@ func (c OptimizelyClient) CreateVariation(flag flag.Flag, variation flag.Variati
 		return err
 	}
 
-	_, err = c.sendHttpRequest("POST", fmt.Sprintf("flags/v1/projects/%d/flags/%s/variations", flag.ProjectId, flag.Key), bytes.NewBuffer(postBody))
+	_, err = c.sendHttpRequest(ctx, "POST", c.flagsURL("projects", strconv.Itoa(flag.ProjectId), "flags", flag.Key, "variations"), bytes.NewBuffer(postBody))
 	return err
 }
 
@@ -42,9 +44,9 @@ type getVariationResponse struct {
 	Items []flag.Variation `json:"items"`
 }
 
-func (c OptimizelyClient) GetVariation(projectId int, flagKey string) ([]flag.Variation, error) {
+func (c OptimizelyClient) GetVariation(ctx context.Context, projectId int, flagKey string) ([]flag.Variation, error) {
 	var variations []flag.Variation
-	respBody, err := c.sendHttpRequest("GET", fmt.Sprintf("flags/v1/projects/%d/flags/%s/variations", projectId, flagKey), nil)
+	respBody, err := c.sendHttpRequest(ctx, "GET", c.flagsURL("projects", strconv.Itoa(projectId), "flags", flagKey, "variations"), nil)
 	if err != nil {
 		return variations, err
 	}
@@ -57,3 +59,69 @@ func (c OptimizelyClient) GetVariation(projectId int, flagKey string) ([]flag.Va
 
 	return getVariationResponse.Items, nil
 }
+
+// UpdateVariation patches the name, description and variable values of a
+// variation from oldVariation to newVariation.
+func (c OptimizelyClient) UpdateVariation(ctx context.Context, flag flag.Flag, oldVariation, newVariation flag.Variation) error {
+	ops := []OptimizelyOp{}
+
+	if oldVariation.Name != newVariation.Name {
+		ops = append(ops, OptimizelyOp{Op: OpReplace, Path: jsonPointer("name"), Value: newVariation.Name})
+	}
+
+	if oldVariation.Description != newVariation.Description {
+		ops = append(ops, OptimizelyOp{Op: OpReplace, Path: jsonPointer("description"), Value: newVariation.Description})
+	}
+
+	var keys []string
+	for key := range oldVariation.Variables {
+		keys = append(keys, key)
+	}
+	for key := range newVariation.Variables {
+		if _, ok := oldVariation.Variables[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		oldValue, inOld := oldVariation.Variables[key]
+		newValue, inNew := newVariation.Variables[key]
+		path := jsonPointer("variables", key)
+
+		switch {
+		case !inNew:
+			ops = append(ops, OptimizelyOp{Op: OpRemove, Path: path})
+		case !inOld:
+			ops = append(ops, OptimizelyOp{Op: OpAdd, Path: path, Value: OptimizelyVariationVariable{Value: newValue}})
+		case oldValue != newValue:
+			ops = append(ops, OptimizelyOp{Op: OpReplace, Path: path, Value: OptimizelyVariationVariable{Value: newValue}})
+		}
+	}
+
+	if len(ops) == 0 {
+		return nil
+	}
+
+	patchBody, err := json.Marshal(ops)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.sendHttpRequest(ctx, "PATCH", c.flagsURL("projects", strconv.Itoa(flag.ProjectId), "flags", flag.Key, "variations", newVariation.Key), bytes.NewBuffer(patchBody))
+	return err
+}
+
+// ArchiveVariations archives the variations of a flag with the given keys,
+// Optimizely doesn't delete variations.
+func (c OptimizelyClient) ArchiveVariations(ctx context.Context, flag flag.Flag, keys []string) error {
+	postBody, err := json.Marshal(map[string]interface{}{
+		"keys": keys,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.sendHttpRequest(ctx, "POST", c.flagsURL("projects", strconv.Itoa(flag.ProjectId), "flags", flag.Key, "variations", "archived"), bytes.NewBuffer(postBody))
+	return err
+}
@@ -0,0 +1,98 @@
+package optimizely
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const DefaultHost = "https://api.optimizely.com"
+
+const TokenEnvVar = "OPTIMIZELY_API_TOKEN"
+
+// resolveToken looks up the API token in order of precedence: the token
+// argument, token_file, token_command and finally the OPTIMIZELY_API_TOKEN
+// environment variable.
+func resolveToken(ctx context.Context, d *schema.ResourceData) (string, diag.Diagnostics) {
+	if token := d.Get("token").(string); token != "" {
+		return token, nil
+	}
+
+	if tokenFile := d.Get("token_file").(string); tokenFile != "" {
+		content, err := ioutil.ReadFile(tokenFile)
+		if err != nil {
+			return "", tokenDiagnostics("Failed to read token_file", "token_file", err)
+		}
+
+		token := strings.TrimSpace(string(content))
+		if token == "" {
+			return "", tokenDiagnostics("Failed to read token_file", "token_file", fmt.Errorf("%s is empty", tokenFile))
+		}
+
+		return token, nil
+	}
+
+	if tokenCommand := d.Get("token_command").([]interface{}); len(tokenCommand) > 0 {
+		token, err := runTokenCommand(ctx, tokenCommand)
+		if err != nil {
+			return "", tokenDiagnostics("token_command failed", "token_command", err)
+		}
+
+		return token, nil
+	}
+
+	if token := os.Getenv(TokenEnvVar); token != "" {
+		return token, nil
+	}
+
+	return "", tokenDiagnostics("Missing Optimizely API token", "", fmt.Errorf("set one of token, token_file, token_command or the %s environment variable", TokenEnvVar))
+}
+
+func tokenDiagnostics(summary, attribute string, err error) diag.Diagnostics {
+	diagnostic := diag.Diagnostic{
+		Severity: diag.Error,
+		Summary:  summary,
+		Detail:   err.Error(),
+	}
+
+	if attribute != "" {
+		diagnostic.AttributePath = cty.GetAttrPath(attribute)
+	}
+
+	return diag.Diagnostics{diagnostic}
+}
+
+func runTokenCommand(ctx context.Context, tokenCommand []interface{}) (string, error) {
+	args := make([]string, len(tokenCommand))
+	for i, arg := range tokenCommand {
+		args[i], _ = arg.(string)
+	}
+
+	if args[0] == "" {
+		return "", fmt.Errorf("token_command must start with the program to run")
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s: %w\n\n%s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("token_command %s printed an empty token", args[0])
+	}
+
+	return token, nil
+}
@@ -0,0 +1,78 @@
+package optimizely
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/client"
+)
+
+func endpointsSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:        schema.TypeList,
+		Optional:    true,
+		MaxItems:    1,
+		Description: "Base URLs of the Optimizely APIs, overriding the ones derived from host",
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"rest_api": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Base URL of the REST v2 API, e.g. " + client.DefaultRestAPI,
+				},
+				"flags_api": {
+					Type:        schema.TypeString,
+					Optional:    true,
+					Description: "Base URL of the Flags v1 API, e.g. " + client.DefaultFlagsAPI,
+				},
+			},
+		},
+	}
+}
+
+// resolveEndpoints returns the base URLs of the REST and Flags APIs: the
+// ones set in the endpoints block, or else the ones derived from host.
+func resolveEndpoints(d *schema.ResourceData) (*url.URL, *url.URL, diag.Diagnostics) {
+	restAPI, flagsAPI, err := client.EndpointsFromHost(d.Get("host").(string))
+	if err != nil {
+		return nil, nil, endpointDiagnostics(err, cty.GetAttrPath("host"))
+	}
+
+	if endpoints, ok := d.Get("endpoints").([]interface{}); ok && len(endpoints) > 0 && endpoints[0] != nil {
+		endpoint := endpoints[0].(map[string]interface{})
+
+		if v := endpoint["rest_api"].(string); v != "" {
+			restAPI = v
+		}
+
+		if v := endpoint["flags_api"].(string); v != "" {
+			flagsAPI = v
+		}
+	}
+
+	restURL, err := client.ParseEndpoint(restAPI)
+	if err != nil {
+		return nil, nil, endpointDiagnostics(err, cty.GetAttrPath("endpoints").IndexInt(0).GetAttr("rest_api"))
+	}
+
+	flagsURL, err := client.ParseEndpoint(flagsAPI)
+	if err != nil {
+		return nil, nil, endpointDiagnostics(err, cty.GetAttrPath("endpoints").IndexInt(0).GetAttr("flags_api"))
+	}
+
+	return restURL, flagsURL, nil
+}
+
+func endpointDiagnostics(err error, path cty.Path) diag.Diagnostics {
+	return diag.Diagnostics{
+		{
+			Severity:      diag.Error,
+			Summary:       "Invalid Optimizely API endpoint",
+			Detail:        fmt.Sprintf("%s", err),
+			AttributePath: path,
+		},
+	}
+}
@@ -0,0 +1,7 @@
+package environment
+
+import "context"
+
+type EnvironmentClient interface {
+	ListEnvironments(ctx context.Context, projectId int) ([]Environment, error)
+}
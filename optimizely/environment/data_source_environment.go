@@ -2,35 +2,129 @@ package environment
 
 import (
 	"context"
-	"strconv"
-	"time"
+	"fmt"
+	"strings"
 
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/apierror"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/project"
 )
 
+type Datafile struct {
+	SDKKey string `json:"sdk_key"`
+	URL    string `json:"url"`
+}
+
+type Environment struct {
+	ID        int64    `json:"id"`
+	ProjectId int      `json:"project_id"`
+	Key       string   `json:"key"`
+	Name      string   `json:"name"`
+	Priority  int      `json:"priority"`
+	IsPrimary bool     `json:"is_primary"`
+	Archived  bool     `json:"archived"`
+	Datafile  Datafile `json:"datafile"`
+}
+
+// environmentFields points a rejected project_id at the project attribute.
+var environmentFields = map[string]string{
+	"project_id": "project",
+}
+
 func DataSourceEnvironment() *schema.Resource {
 	return &schema.Resource{
-		ReadContext: dataSourceIngredientsRead,
+		ReadContext: dataSourceEnvironmentRead,
 		Schema: map[string]*schema.Schema{
 			"id": {
-				Type:     schema.TypeString,
-				Computed: true,
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The key of the Environment, as rules reference it",
+			},
+			"project": {
+				Type:        schema.TypeInt,
+				Optional:    true,
+				Computed:    true,
+				Description: "Project ID, defaults to the provider project_id",
 			},
 			"key": {
 				Type:     schema.TypeString,
 				Required: true,
 			},
+			"environment_id": {
+				Type:        schema.TypeInt,
+				Computed:    true,
+				Description: "The numeric ID of the Environment",
+			},
+			"name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"priority": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"is_primary": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"archived": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"sdk_key": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The SDK key of the Environment's datafile",
+			},
+			"datafile_url": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 		},
 	}
 }
 
-func dataSourceIngredientsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
+func dataSourceEnvironmentRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(EnvironmentClient)
 
-	d.SetId(strconv.FormatInt(time.Now().Unix(), 10))
-	// fmt.Printf("----- %+v \n\n", d.Get("key"))
-	d.SetId(d.Get("key").(string))
+	projectId := project.ResolveProjectId(d, m)
+	if projectId == 0 {
+		return diag.Errorf("project is not set: set it on the data source or set project_id on the provider")
+	}
+
+	envs, err := client.ListEnvironments(ctx, projectId)
+	if err != nil {
+		return apierror.Diagnostics("Failed to list Environments from Optimizely", err, environmentFields)
+	}
+
+	key := d.Get("key").(string)
+
+	var keys []string
+	for _, env := range envs {
+		if env.Key != key {
+			keys = append(keys, env.Key)
+			continue
+		}
+
+		d.SetId(env.Key)
+		d.Set("project", projectId)
+		d.Set("environment_id", env.ID)
+		d.Set("name", env.Name)
+		d.Set("priority", env.Priority)
+		d.Set("is_primary", env.IsPrimary)
+		d.Set("archived", env.Archived)
+		d.Set("sdk_key", env.Datafile.SDKKey)
+		d.Set("datafile_url", env.Datafile.URL)
+
+		return nil
+	}
 
-	return diags
+	return diag.Diagnostics{{
+		Severity:      diag.Error,
+		Summary:       "Environment not found in Optimizely",
+		Detail:        fmt.Sprintf("project %d has no environment %q, its environments are: %s", projectId, key, strings.Join(keys, ", ")),
+		AttributePath: cty.GetAttrPath("key"),
+	}}
 }
@@ -0,0 +1,188 @@
+package flag
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// maxConditionDepth is how deep audience_conditions groups nest, schemas
+// can't be recursive.
+const maxConditionDepth = 4
+
+const (
+	ConditionAnd = "and"
+	ConditionOr  = "or"
+	ConditionNot = "not"
+)
+
+// audienceConditionGroup returns the schema of a group of audience
+// conditions nesting groups depth levels deep.
+func audienceConditionGroup(depth int) *schema.Resource {
+	group := map[string]*schema.Schema{
+		"operator": {
+			Type:         schema.TypeString,
+			Optional:     true,
+			Default:      ConditionAnd,
+			ValidateFunc: validation.StringInSlice([]string{ConditionAnd, ConditionOr, ConditionNot}, false),
+		},
+		"audiences": {
+			Type:     schema.TypeList,
+			Optional: true,
+			Elem: &schema.Schema{
+				Type: schema.TypeString,
+			},
+		},
+	}
+
+	if depth > 1 {
+		group["group"] = &schema.Schema{
+			Type:     schema.TypeList,
+			Optional: true,
+			Elem:     audienceConditionGroup(depth - 1),
+		}
+	}
+
+	return &schema.Resource{Schema: group}
+}
+
+// parseAudiences returns the conditions of a rule targeting all the given
+// audiences.
+func parseAudiences(audiences []interface{}) []Condition {
+	conditions := []Condition{ConditionAnd}
+	for _, audId := range audiences {
+		audIdInt, _ := strconv.ParseInt(audId.(string), 10, 64)
+		conditions = append(conditions, AudienceCondition{AudienceID: audIdInt})
+	}
+	return conditions
+}
+
+// parseConditionGroup turns a group block into Optimizely's condition array:
+// the operator, then the audiences, then the nested groups.
+func parseConditionGroup(group map[string]interface{}) []Condition {
+	conditions := parseAudiences(group["audiences"].([]interface{}))
+	conditions[0] = group["operator"].(string)
+
+	nested, _ := group["group"].([]interface{})
+	for _, g := range nested {
+		if gMap, ok := g.(map[string]interface{}); ok {
+			conditions = append(conditions, parseConditionGroup(gMap))
+		}
+	}
+
+	return conditions
+}
+
+// isAudienceList tells whether conditions target all of a list of audiences,
+// and can be written with the audience attribute.
+func isAudienceList(conditions []Condition) bool {
+	for i, cond := range conditions {
+		if i == 0 {
+			if operator, ok := cond.(string); ok {
+				if operator != ConditionAnd {
+					return false
+				}
+				continue
+			}
+		}
+
+		if _, ok := cond.(AudienceCondition); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func flattenAudiences(conditions []Condition) []interface{} {
+	audiences := []interface{}{}
+	for _, cond := range conditions {
+		if audCond, ok := cond.(AudienceCondition); ok {
+			audiences = append(audiences, strconv.FormatInt(audCond.AudienceID, 10))
+		}
+	}
+	return audiences
+}
+
+// flattenConditionGroup turns Optimizely's condition array back into a group
+// block. Like Optimizely, an array without operator combines its items with
+// "or"; groups nested deeper than depth are left out, readFlag rejects such
+// conditions before they get here.
+func flattenConditionGroup(conditions []Condition, depth int) map[string]interface{} {
+	operator := ConditionOr
+	groups := []interface{}{}
+
+	for i, cond := range conditions {
+		switch c := cond.(type) {
+		case string:
+			if i == 0 {
+				operator = c
+			}
+		case []Condition:
+			if depth > 1 {
+				groups = append(groups, flattenConditionGroup(c, depth-1))
+			}
+		}
+	}
+
+	group := map[string]interface{}{
+		"operator":  operator,
+		"audiences": flattenAudiences(conditions),
+	}
+
+	if depth > 1 {
+		group["group"] = groups
+	}
+
+	return group
+}
+
+// conditionDepth returns how many levels of groups conditions nest, 1 for an
+// array without nested arrays.
+func conditionDepth(conditions []Condition) int {
+	depth := 1
+	for _, cond := range conditions {
+		if nested, ok := cond.([]Condition); ok {
+			if nestedDepth := conditionDepth(nested) + 1; nestedDepth > depth {
+				depth = nestedDepth
+			}
+		}
+	}
+	return depth
+}
+
+// checkConditionDepth rejects rules whose audience conditions nest deeper
+// than audience_conditions can: reading them would drop the deepest groups,
+// and the next apply would overwrite the targeting set in Optimizely.
+func checkConditionDepth(envs map[string]FeatureEnvironment) error {
+	for env, flagEnv := range envs {
+		for _, rule := range flagEnv.RolloutRules {
+			if depth := conditionDepth(rule.AudienceConditions); depth > maxConditionDepth {
+				return fmt.Errorf("rule %s in %s nests audience conditions %d levels deep, audience_conditions supports %d", rule.Key, env, depth, maxConditionDepth)
+			}
+		}
+	}
+	return nil
+}
+
+// validateConditionGroup checks that a "not" group negates exactly one
+// audience or group, Optimizely ignores the others.
+func validateConditionGroup(group map[string]interface{}) error {
+	audiences, _ := group["audiences"].([]interface{})
+	nested, _ := group["group"].([]interface{})
+
+	if group["operator"] == ConditionNot && len(audiences)+len(nested) != 1 {
+		return fmt.Errorf("a %q group negates exactly one audience or group, got %d", ConditionNot, len(audiences)+len(nested))
+	}
+
+	for _, g := range nested {
+		if gMap, ok := g.(map[string]interface{}); ok {
+			if err := validateConditionGroup(gMap); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
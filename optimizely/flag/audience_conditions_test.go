@@ -0,0 +1,63 @@
+package flag
+
+import (
+	"strings"
+	"testing"
+)
+
+// nestConditions returns "or" conditions nesting groups levels deep around
+// a single audience.
+func nestConditions(levels int) []Condition {
+	conditions := []Condition{ConditionOr, AudienceCondition{1}}
+	for i := 1; i < levels; i++ {
+		conditions = []Condition{ConditionAnd, conditions}
+	}
+	return conditions
+}
+
+func TestCheckConditionDepth(t *testing.T) {
+	cases := []struct {
+		name       string
+		conditions []Condition
+		err        string
+	}{
+		{
+			name:       "audience list",
+			conditions: []Condition{ConditionAnd, AudienceCondition{1}, AudienceCondition{2}},
+		},
+		{
+			name:       "as deep as the schema",
+			conditions: nestConditions(maxConditionDepth),
+		},
+		{
+			name:       "deeper than the schema",
+			conditions: nestConditions(maxConditionDepth + 1),
+			err:        "rule everyone in development nests audience conditions 5 levels deep, audience_conditions supports 4",
+		},
+	}
+
+	for _, c := range cases {
+		envs := map[string]FeatureEnvironment{
+			"development": {RolloutRules: []RolloutRule{{Key: "everyone", AudienceConditions: c.conditions}}},
+		}
+
+		err := checkConditionDepth(envs)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", c.name, err)
+		case c.err != "" && err == nil:
+			t.Errorf("%s: expected an error matching %q", c.name, c.err)
+		case c.err != "" && !strings.Contains(err.Error(), c.err):
+			t.Errorf("%s: got error %q, want %q", c.name, err, c.err)
+		}
+	}
+}
+
+func TestFlattenConditionGroupRoundTrip(t *testing.T) {
+	conditions := nestConditions(maxConditionDepth)
+
+	got := parseConditionGroup(flattenConditionGroup(conditions, maxConditionDepth))
+	if conditionDepth(got) != maxConditionDepth {
+		t.Errorf("expected %d levels back, got %v", maxConditionDepth, got)
+	}
+}
@@ -1,16 +1,24 @@
 package flag
 
+import "context"
+
 type FlagClient interface {
-	CreateFlag(flag Flag) (Flag, error)
-	GetFlag(projectId int, flagKey string) (Flag, error)
-	DeleteFlag(projectId int, flagKey string) error
+	CreateFlag(ctx context.Context, flag Flag) (Flag, error)
+	GetFlag(ctx context.Context, projectId int, flagKey string) (Flag, error)
+	UpdateFlag(ctx context.Context, oldFlag, newFlag Flag) error
+	DeleteFlag(ctx context.Context, projectId int, flagKey string) error
+	ArchiveFlag(ctx context.Context, projectId int, flagKey string) error
+
+	CreateRuleset(ctx context.Context, flag Flag) error
+	UpdateRuleset(ctx context.Context, oldFlag, newFlag Flag) error
+	GetRuleset(ctx context.Context, flag Flag) (map[string]FeatureEnvironment, error)
+	EnableRuleset(ctx context.Context, flag Flag) error
+	DisableRuleset(ctx context.Context, flag Flag) error
 
-	CreateRuleset(flag Flag) error
-	UpdateRuleset(flag Flag) error
-	GetRuleset(flag Flag) (map[string]FeatureEnvironment, error)
-	EnableRuleset(flag Flag) error
-	DisableRuleset(flag Flag) error
+	ListEnvironmentKeys(ctx context.Context, projectId int) ([]string, error)
 
-	CreateVariation(flag Flag, variation Variation) error
-	GetVariation(projectId int, flagKey string) ([]Variation, error)
+	CreateVariation(ctx context.Context, flag Flag, variation Variation) error
+	GetVariation(ctx context.Context, projectId int, flagKey string) ([]Variation, error)
+	UpdateVariation(ctx context.Context, flag Flag, oldVariation, newVariation Variation) error
+	ArchiveVariations(ctx context.Context, flag Flag, keys []string) error
 }
@@ -1,20 +1,48 @@
 package flag
 
 import (
-	"strconv"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 )
 
 type FeatureEnvironment struct {
 	RolloutRules []RolloutRule `json:"rollout_rules"`
+	Enabled      bool          `json:"enabled"`
 }
 
+const (
+	RuleTypeTargetedDelivery = "targeted_delivery"
+	RuleTypeABTest           = "a/b"
+)
+
 type RolloutRule struct {
-	Key                string      `json:"key"`
-	AudienceConditions []Condition `json:"audience_conditions"`
-	PercentageIncluded int         `json:"percentage_included"`
-	Deliver            string      `json:"deliver"`
+	Key                string          `json:"key"`
+	Type               string          `json:"type"`
+	AudienceConditions []Condition     `json:"audience_conditions"`
+	PercentageIncluded int             `json:"percentage_included"`
+	Deliver            string          `json:"deliver"`
+	Variations         []RuleVariation `json:"variations"`
+	Metrics            []Metric        `json:"metrics"`
+	DistributionMode   string          `json:"distribution_mode"`
+}
+
+// RuleVariation is a variation served by an A/B test rule, with its share
+// of the traffic.
+type RuleVariation struct {
+	Key    string `json:"key"`
+	Weight int    `json:"weight"`
+}
+
+// Metric is an event measured by an A/B test rule, the first one is the
+// primary metric.
+type Metric struct {
+	EventID          int64  `json:"event_id"`
+	Aggregator       string `json:"aggregator"`
+	WinningDirection string `json:"winning_direction"`
 }
 
 type Condition interface{}
@@ -23,29 +51,31 @@ type AudienceCondition struct {
 	AudienceID int64 `json:"audience_id"`
 }
 
-func parseEnvironment(d *schema.ResourceData) map[string]FeatureEnvironment {
+func parseEnvironment(raw []interface{}) map[string]FeatureEnvironment {
 	var envs = make(map[string]FeatureEnvironment)
 
-	for _, rules := range d.Get("rules").([]interface{}) {
+	for _, rules := range raw {
 		rule := rules.(map[string]interface{})["rule"]
 		for _, r := range rule.([]interface{}) {
 
 			rMap := r.(map[string]interface{})
 			environments := rMap["environments"].([]interface{})
 			for _, env := range environments {
-				audConditions := []Condition{"and"}
-
-				for _, audId := range rMap["audience"].([]interface{}) {
-					audIdInt, _ := strconv.ParseInt(audId.(string), 10, 64)
-					audConditions = append(audConditions, AudienceCondition{AudienceID: audIdInt})
+				audConditions := parseAudiences(rMap["audience"].([]interface{}))
+				if groups, _ := rMap["audience_conditions"].([]interface{}); len(groups) > 0 && groups[0] != nil {
+					audConditions = parseConditionGroup(groups[0].(map[string]interface{}))
 				}
 
 				rollout := rMap["percentage_included"].(int)
 				rolloutRule := RolloutRule{
 					Key:                rMap["key"].(string),
+					Type:               rMap["type"].(string),
 					AudienceConditions: audConditions,
 					PercentageIncluded: rollout * 100, // TODO mover pro client impl
 					Deliver:            rMap["deliver"].(string),
+					Variations:         parseRuleVariations(rMap["variation"]),
+					Metrics:            parseMetrics(rMap["metric"]),
+					DistributionMode:   rMap["distribution_mode"].(string),
 				}
 
 				if featureEnvironment, ok := envs[env.(string)]; ok {
@@ -66,3 +96,299 @@ func parseEnvironment(d *schema.ResourceData) map[string]FeatureEnvironment {
 
 	return envs
 }
+
+// parseEnvironmentStates returns the enabled state of each environment with
+// an environment block.
+func parseEnvironmentStates(raw interface{}) map[string]bool {
+	states := make(map[string]bool)
+	for _, eMap := range setElements(raw) {
+		states[eMap["key"].(string)] = eMap["enabled"].(bool)
+	}
+
+	return states
+}
+
+// rulesetStates returns whether the ruleset of each environment of the flag
+// or of declared should be enabled: as set by its environment block, or
+// enabled when it has rules.
+func rulesetStates(flag Flag, declared map[string]bool) map[string]bool {
+	states := make(map[string]bool)
+	for env, flagEnv := range flag.Environments {
+		states[env] = len(flagEnv.RolloutRules) > 0
+	}
+	for env, enabled := range declared {
+		states[env] = enabled
+	}
+	return states
+}
+
+// flattenEnvironments returns the environment blocks of the environments in
+// prior, the ones currently in state, and of the environments whose ruleset
+// isn't in its default state, so enabling or disabling it in the UI shows up
+// as drift.
+func flattenEnvironments(envs map[string]FeatureEnvironment, envKeys []string, prior interface{}) []interface{} {
+	declared := parseEnvironmentStates(prior)
+
+	environments := []interface{}{}
+	for _, env := range envKeys {
+		flagEnv := envs[env]
+		if _, ok := declared[env]; !ok && flagEnv.Enabled == (len(flagEnv.RolloutRules) > 0) {
+			continue
+		}
+
+		environments = append(environments, map[string]interface{}{
+			"key":     env,
+			"enabled": flagEnv.Enabled,
+		})
+	}
+
+	return environments
+}
+
+// parseRuleVariations returns the variations of an A/B test rule sorted by
+// key, with their weight in basis points like percentage_included.
+func parseRuleVariations(raw interface{}) []RuleVariation {
+	variations := []RuleVariation{}
+
+	for _, vMap := range setElements(raw) {
+		variations = append(variations, RuleVariation{
+			Key:    vMap["key"].(string),
+			Weight: vMap["weight"].(int) * 100,
+		})
+	}
+
+	sort.Slice(variations, func(i, j int) bool {
+		return variations[i].Key < variations[j].Key
+	})
+
+	return variations
+}
+
+func parseMetrics(raw interface{}) []Metric {
+	metrics := []Metric{}
+
+	list, _ := raw.([]interface{})
+	for _, m := range list {
+		mMap := m.(map[string]interface{})
+		metrics = append(metrics, Metric{
+			EventID:          int64(mMap["event_id"].(int)),
+			Aggregator:       mMap["aggregator"].(string),
+			WinningDirection: mMap["winning_direction"].(string),
+		})
+	}
+
+	return metrics
+}
+
+// flattenRules turns the rules of each environment back into rule blocks.
+// The blocks in prior, the rules currently in state, keep their order and
+// environments as long as Optimizely still has them, so refreshing doesn't
+// reshuffle the configuration. Rules not matched by prior follow, in the order
+// of envKeys and then of their priority, with rules set identically in
+// several environments merged into one block.
+func flattenRules(envs map[string]FeatureEnvironment, envKeys []string, prior []interface{}) []interface{} {
+	remaining := make(map[string][]RolloutRule)
+	for env, flagEnv := range envs {
+		remaining[env] = flagEnv.RolloutRules
+	}
+
+	take := func(env, key string) (RolloutRule, bool) {
+		for i, rolloutRule := range remaining[env] {
+			if rolloutRule.Key == key {
+				remaining[env] = append(remaining[env][:i:i], remaining[env][i+1:]...)
+				return rolloutRule, true
+			}
+		}
+		return RolloutRule{}, false
+	}
+
+	var rules []map[string]interface{}
+
+	for _, priorRules := range prior {
+		priorMap, ok := priorRules.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, r := range priorMap["rule"].([]interface{}) {
+			rMap := r.(map[string]interface{})
+
+			structured := len(rMap["audience_conditions"].([]interface{})) > 0
+
+			var block []map[string]interface{}
+			for _, env := range rMap["environments"].([]interface{}) {
+				if rolloutRule, ok := take(env.(string), rMap["key"].(string)); ok {
+					block = appendRule(block, env.(string), rolloutRule, structured)
+				}
+			}
+			rules = append(rules, block...)
+		}
+	}
+
+	var added []map[string]interface{}
+	for _, env := range envKeys {
+		for _, rolloutRule := range remaining[env] {
+			added = appendRule(added, env, rolloutRule, false)
+		}
+	}
+	rules = append(rules, added...)
+
+	if len(rules) == 0 {
+		return []interface{}{}
+	}
+
+	ruleList := make([]interface{}, len(rules))
+	for i, rule := range rules {
+		ruleList[i] = rule
+	}
+
+	return []interface{}{
+		map[string]interface{}{"rule": ruleList},
+	}
+}
+
+// appendRule adds env to the block of rules with the same key and settings,
+// or appends a new block. Its audiences are written with audience_conditions
+// when structured is set or they aren't a plain list of audiences.
+func appendRule(rules []map[string]interface{}, env string, rolloutRule RolloutRule, structured bool) []map[string]interface{} {
+	audiences := []interface{}{}
+	audienceConditions := []interface{}{}
+	if structured || !isAudienceList(rolloutRule.AudienceConditions) {
+		audienceConditions = append(audienceConditions, flattenConditionGroup(rolloutRule.AudienceConditions, maxConditionDepth))
+	} else {
+		audiences = flattenAudiences(rolloutRule.AudienceConditions)
+	}
+
+	variations := []interface{}{}
+	for _, variation := range rolloutRule.Variations {
+		variations = append(variations, map[string]interface{}{
+			"key":    variation.Key,
+			"weight": variation.Weight,
+		})
+	}
+
+	metrics := []interface{}{}
+	for i, metric := range rolloutRule.Metrics {
+		metrics = append(metrics, map[string]interface{}{
+			"event_id":          int(metric.EventID),
+			"aggregator":        metric.Aggregator,
+			"winning_direction": metric.WinningDirection,
+			"primary":           i == 0,
+		})
+	}
+
+	ruleType := rolloutRule.Type
+	if ruleType == "" {
+		ruleType = RuleTypeTargetedDelivery
+	}
+
+	distributionMode := rolloutRule.DistributionMode
+	if distributionMode == "" {
+		distributionMode = "manual"
+	}
+
+	block := map[string]interface{}{
+		"key":                 rolloutRule.Key,
+		"type":                ruleType,
+		"audience":            audiences,
+		"audience_conditions": audienceConditions,
+		"percentage_included": rolloutRule.PercentageIncluded,
+		"deliver":             rolloutRule.Deliver,
+		"variation":           variations,
+		"metric":              metrics,
+		"distribution_mode":   distributionMode,
+	}
+
+	for _, rule := range rules {
+		if sameRule(rule, block) {
+			rule["environments"] = append(rule["environments"].([]interface{}), env)
+			return rules
+		}
+	}
+
+	block["environments"] = []interface{}{env}
+	return append(rules, block)
+}
+
+// sameRule compares two rule blocks, ignoring their environments.
+func sameRule(rule, other map[string]interface{}) bool {
+	for key, value := range other {
+		if key != "environments" && !reflect.DeepEqual(rule[key], value) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRules checks at plan time that rules set their audiences once,
+// that targeted delivery rules deliver a single variation and that A/B test
+// rules split all their traffic between variations.
+func validateRules(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	return checkRules(d.Get("rules").([]interface{}), d.NewValueKnown)
+}
+
+// checkRules validates the rules blocks for validateRules, known tells
+// whether the value at a path of the plan is known yet.
+func checkRules(raw []interface{}, known func(key string) bool) error {
+	for j, rules := range raw {
+		rulesMap, ok := rules.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		for i, r := range rulesMap["rule"].([]interface{}) {
+			rMap := r.(map[string]interface{})
+			key := rMap["key"].(string)
+			variations := rMap["variation"].(*schema.Set).List()
+
+			if groups := rMap["audience_conditions"].([]interface{}); len(groups) > 0 {
+				if len(rMap["audience"].([]interface{})) > 0 {
+					return fmt.Errorf("rule %s: audience and audience_conditions can't be set together", key)
+				}
+
+				if group, ok := groups[0].(map[string]interface{}); ok {
+					if err := validateConditionGroup(group); err != nil {
+						return fmt.Errorf("rule %s: %w", key, err)
+					}
+				}
+			}
+
+			switch rMap["type"].(string) {
+			case RuleTypeABTest:
+				if rMap["deliver"].(string) != "" {
+					return fmt.Errorf("rule %s: deliver is only supported by %s rules, %s rules split traffic with variation blocks", key, RuleTypeTargetedDelivery, RuleTypeABTest)
+				}
+
+				if len(variations) == 0 {
+					return fmt.Errorf("rule %s: %s rules need at least one variation block", key, RuleTypeABTest)
+				}
+
+				if !known(fmt.Sprintf("rules.%d.rule.%d.variation", j, i)) {
+					continue
+				}
+
+				total := 0
+				for _, v := range variations {
+					total += v.(map[string]interface{})["weight"].(int)
+				}
+				if total != 100 {
+					return fmt.Errorf("rule %s: variation weights must add up to 100, got %d", key, total)
+				}
+
+			default:
+				// deliver may come from another resource, and only be known
+				// at apply time
+				deliverKnown := known(fmt.Sprintf("rules.%d.rule.%d.deliver", j, i))
+				if deliverKnown && rMap["deliver"].(string) == "" {
+					return fmt.Errorf("rule %s: %s rules need deliver", key, RuleTypeTargetedDelivery)
+				}
+
+				if len(variations) > 0 || len(rMap["metric"].([]interface{})) > 0 {
+					return fmt.Errorf("rule %s: variation and metric blocks are only supported by %s rules", key, RuleTypeABTest)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,318 @@
+package flag
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// testRule returns a rule block as the plan reads it, a targeted delivery to
+// development unless fields say otherwise.
+func testRule(key string, fields map[string]interface{}) map[string]interface{} {
+	rule := map[string]interface{}{
+		"key":                 key,
+		"type":                RuleTypeTargetedDelivery,
+		"environments":        []interface{}{"development"},
+		"audience":            []interface{}{},
+		"audience_conditions": []interface{}{},
+		"percentage_included": 100,
+		"deliver":             "on",
+		"variation":           testVariations(nil),
+		"metric":              []interface{}{},
+		"distribution_mode":   "manual",
+	}
+
+	for field, value := range fields {
+		rule[field] = value
+	}
+
+	return rule
+}
+
+func testRules(rules ...interface{}) []interface{} {
+	return []interface{}{map[string]interface{}{"rule": rules}}
+}
+
+func testVariations(weights map[string]int) *schema.Set {
+	set := schema.NewSet(func(v interface{}) int {
+		return schema.HashString(v.(map[string]interface{})["key"])
+	}, nil)
+
+	for key, weight := range weights {
+		set.Add(map[string]interface{}{"key": key, "weight": weight})
+	}
+
+	return set
+}
+
+func TestParseEnvironment(t *testing.T) {
+	cases := []struct {
+		name  string
+		rules []interface{}
+		want  map[string]FeatureEnvironment
+	}{
+		{
+			name:  "no rules",
+			rules: []interface{}{},
+			want:  map[string]FeatureEnvironment{},
+		},
+		{
+			name: "rule in two environments",
+			rules: testRules(
+				testRule("everyone", map[string]interface{}{
+					"environments":        []interface{}{"development", "production"},
+					"audience":            []interface{}{"1", "2"},
+					"percentage_included": 50,
+				}),
+			),
+			want: map[string]FeatureEnvironment{
+				"development": {RolloutRules: []RolloutRule{
+					{Key: "everyone", Type: RuleTypeTargetedDelivery, AudienceConditions: []Condition{ConditionAnd, AudienceCondition{1}, AudienceCondition{2}}, PercentageIncluded: 5000, Deliver: "on", Variations: []RuleVariation{}, Metrics: []Metric{}, DistributionMode: "manual"},
+				}},
+				"production": {RolloutRules: []RolloutRule{
+					{Key: "everyone", Type: RuleTypeTargetedDelivery, AudienceConditions: []Condition{ConditionAnd, AudienceCondition{1}, AudienceCondition{2}}, PercentageIncluded: 5000, Deliver: "on", Variations: []RuleVariation{}, Metrics: []Metric{}, DistributionMode: "manual"},
+				}},
+			},
+		},
+		{
+			name: "rules keep their order and audience_conditions win",
+			rules: testRules(
+				testRule("first", map[string]interface{}{
+					"audience_conditions": []interface{}{
+						map[string]interface{}{"operator": ConditionOr, "audiences": []interface{}{"3"}},
+					},
+				}),
+				testRule("second", map[string]interface{}{
+					"type":      RuleTypeABTest,
+					"deliver":   "",
+					"variation": testVariations(map[string]int{"on": 25, "off": 75}),
+				}),
+			),
+			want: map[string]FeatureEnvironment{
+				"development": {RolloutRules: []RolloutRule{
+					{Key: "first", Type: RuleTypeTargetedDelivery, AudienceConditions: []Condition{ConditionOr, AudienceCondition{3}}, PercentageIncluded: 10000, Deliver: "on", Variations: []RuleVariation{}, Metrics: []Metric{}, DistributionMode: "manual"},
+					{Key: "second", Type: RuleTypeABTest, AudienceConditions: []Condition{ConditionAnd}, PercentageIncluded: 10000, Variations: []RuleVariation{{"off", 7500}, {"on", 2500}}, Metrics: []Metric{}, DistributionMode: "manual"},
+				}},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		got := parseEnvironment(c.rules)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
+
+// ruleSummary describes flattened rule blocks as key:environments, with a
+// trailing * for the blocks written with audience_conditions.
+func ruleSummary(flat []interface{}) []string {
+	summary := []string{}
+	for _, rules := range flat {
+		for _, r := range rules.(map[string]interface{})["rule"].([]interface{}) {
+			rMap := r.(map[string]interface{})
+
+			var envs []string
+			for _, env := range rMap["environments"].([]interface{}) {
+				envs = append(envs, env.(string))
+			}
+
+			line := fmt.Sprintf("%s:%s", rMap["key"], strings.Join(envs, ","))
+			if len(rMap["audience_conditions"].([]interface{})) > 0 {
+				line += "*"
+			}
+			summary = append(summary, line)
+		}
+	}
+	return summary
+}
+
+func TestFlattenRules(t *testing.T) {
+	deliver := func(key string, percentage int, conditions ...Condition) RolloutRule {
+		if len(conditions) == 0 {
+			conditions = []Condition{ConditionAnd}
+		}
+		return RolloutRule{Key: key, Type: RuleTypeTargetedDelivery, AudienceConditions: conditions, PercentageIncluded: percentage, Deliver: "on"}
+	}
+
+	envKeys := []string{"development", "production"}
+
+	cases := []struct {
+		name  string
+		envs  map[string]FeatureEnvironment
+		prior []interface{}
+		want  []string
+	}{
+		{
+			name: "no rules",
+			envs: map[string]FeatureEnvironment{"development": {}},
+			want: []string{},
+		},
+		{
+			name: "same rule in both environments shares a block",
+			envs: map[string]FeatureEnvironment{
+				"production":  {RolloutRules: []RolloutRule{deliver("everyone", 100)}},
+				"development": {RolloutRules: []RolloutRule{deliver("everyone", 100)}},
+			},
+			want: []string{"everyone:development,production"},
+		},
+		{
+			name: "different settings split the block",
+			envs: map[string]FeatureEnvironment{
+				"development": {RolloutRules: []RolloutRule{deliver("everyone", 100)}},
+				"production":  {RolloutRules: []RolloutRule{deliver("everyone", 50)}},
+			},
+			want: []string{"everyone:development", "everyone:production"},
+		},
+		{
+			name: "prior order is kept and new rules come last",
+			envs: map[string]FeatureEnvironment{
+				"development": {RolloutRules: []RolloutRule{deliver("a", 100), deliver("b", 100), deliver("c", 100)}},
+			},
+			prior: testRules(
+				testRule("b", nil),
+				testRule("a", nil),
+			),
+			want: []string{"b:development", "a:development", "c:development"},
+		},
+		{
+			name: "rules gone from Optimizely are dropped",
+			envs: map[string]FeatureEnvironment{
+				"development": {RolloutRules: []RolloutRule{deliver("a", 100)}},
+			},
+			prior: testRules(
+				testRule("gone", nil),
+				testRule("a", nil),
+			),
+			want: []string{"a:development"},
+		},
+		{
+			name: "audience_conditions are kept from prior or for non list conditions",
+			envs: map[string]FeatureEnvironment{
+				"development": {RolloutRules: []RolloutRule{
+					deliver("structured", 100, ConditionAnd, AudienceCondition{1}),
+					deliver("or", 100, ConditionOr, AudienceCondition{1}, AudienceCondition{2}),
+					deliver("list", 100, ConditionAnd, AudienceCondition{1}),
+				}},
+			},
+			prior: testRules(
+				testRule("structured", map[string]interface{}{
+					"audience_conditions": []interface{}{
+						map[string]interface{}{"operator": ConditionAnd, "audiences": []interface{}{"1"}},
+					},
+				}),
+			),
+			want: []string{"structured:development*", "or:development*", "list:development"},
+		},
+	}
+
+	for _, c := range cases {
+		got := ruleSummary(flattenRules(c.envs, envKeys, c.prior))
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCheckRules(t *testing.T) {
+	abTest := func(fields map[string]interface{}) map[string]interface{} {
+		rule := testRule("experiment", map[string]interface{}{
+			"type":      RuleTypeABTest,
+			"deliver":   "",
+			"variation": testVariations(map[string]int{"on": 50, "off": 50}),
+		})
+		for field, value := range fields {
+			rule[field] = value
+		}
+		return rule
+	}
+
+	cases := []struct {
+		name    string
+		rule    map[string]interface{}
+		unknown string
+		err     string
+	}{
+		{
+			name: "targeted delivery",
+			rule: testRule("everyone", nil),
+		},
+		{
+			name: "targeted delivery without deliver",
+			rule: testRule("everyone", map[string]interface{}{"deliver": ""}),
+			err:  `rule everyone: targeted_delivery rules need deliver`,
+		},
+		{
+			name:    "targeted delivery with unknown deliver",
+			rule:    testRule("everyone", map[string]interface{}{"deliver": ""}),
+			unknown: "rules.0.rule.0.deliver",
+		},
+		{
+			name: "targeted delivery with variations",
+			rule: testRule("everyone", map[string]interface{}{"variation": testVariations(map[string]int{"on": 100})}),
+			err:  `variation and metric blocks are only supported by a/b rules`,
+		},
+		{
+			name: "targeted delivery with metrics",
+			rule: testRule("everyone", map[string]interface{}{"metric": []interface{}{map[string]interface{}{"event_id": 1}}}),
+			err:  `variation and metric blocks are only supported by a/b rules`,
+		},
+		{
+			name: "audience and audience_conditions",
+			rule: testRule("everyone", map[string]interface{}{
+				"audience":            []interface{}{"1"},
+				"audience_conditions": []interface{}{map[string]interface{}{"operator": ConditionAnd, "audiences": []interface{}{"2"}}},
+			}),
+			err: `audience and audience_conditions can't be set together`,
+		},
+		{
+			name: "not group with two audiences",
+			rule: testRule("everyone", map[string]interface{}{
+				"audience_conditions": []interface{}{map[string]interface{}{"operator": ConditionNot, "audiences": []interface{}{"1", "2"}}},
+			}),
+			err: `rule everyone: a "not" group negates exactly one audience or group, got 2`,
+		},
+		{
+			name: "a/b test",
+			rule: abTest(nil),
+		},
+		{
+			name: "a/b test with deliver",
+			rule: abTest(map[string]interface{}{"deliver": "on"}),
+			err:  `deliver is only supported by targeted_delivery rules`,
+		},
+		{
+			name: "a/b test without variations",
+			rule: abTest(map[string]interface{}{"variation": testVariations(nil)}),
+			err:  `a/b rules need at least one variation block`,
+		},
+		{
+			name: "a/b test weights not adding up",
+			rule: abTest(map[string]interface{}{"variation": testVariations(map[string]int{"on": 50, "off": 40})}),
+			err:  `variation weights must add up to 100, got 90`,
+		},
+		{
+			name:    "a/b test with unknown weights",
+			rule:    abTest(map[string]interface{}{"variation": testVariations(map[string]int{"on": 50, "off": 0})}),
+			unknown: "rules.0.rule.0.variation",
+		},
+	}
+
+	for _, c := range cases {
+		known := func(key string) bool { return key != c.unknown }
+
+		err := checkRules(testRules(c.rule), known)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", c.name, err)
+		case c.err != "" && err == nil:
+			t.Errorf("%s: expected an error matching %q", c.name, c.err)
+		case c.err != "" && !strings.Contains(err.Error(), c.err):
+			t.Errorf("%s: got error %q, want %q", c.name, err, c.err)
+		}
+	}
+}
@@ -2,14 +2,33 @@ package flag
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/hashicorp/terraform-plugin-log/tflog"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/apierror"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/project"
 )
 
+var flagFields = map[string]string{
+	"project_id":           "project",
+	"key":                  "key",
+	"name":                 "name",
+	"description":          "description",
+	"variable_definitions": "variable_schema",
+	"variations":           "variations",
+	"rules":                "rules",
+	"rule_priorities":      "rules",
+}
+
 type Flag struct {
 	ID           int64                         `json:"id"`
 	ProjectId    int                           `json:"project_id"`
@@ -27,9 +46,10 @@ func ResourceFeature() *schema.Resource {
 		Schema: map[string]*schema.Schema{
 			"project": {
 				Type:        schema.TypeInt,
-				Required:    true,
+				Optional:    true,
+				Computed:    true,
 				ForceNew:    true,
-				Description: "Project ID",
+				Description: "Project ID, defaults to the provider project_id",
 			},
 			"key": {
 				Type:        schema.TypeString,
@@ -40,41 +60,34 @@ func ResourceFeature() *schema.Resource {
 			"name": {
 				Type:        schema.TypeString,
 				Required:    true,
-				ForceNew:    true,
 				Description: "Human readable name",
 			},
 			"description": {
 				Type:        schema.TypeString,
 				Required:    true,
-				ForceNew:    true,
 				Description: "A description of this feature",
 			},
 			"variable_schema": {
 				Type:     schema.TypeList,
 				Optional: true,
-				ForceNew: true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"variable": {
-							Type:     schema.TypeList,
+							Type:     schema.TypeSet,
 							Required: true,
-							ForceNew: true,
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
 									"key": {
 										Type:     schema.TypeString,
 										Required: true,
-										ForceNew: true,
 									},
 									"type": {
 										Type:     schema.TypeString,
 										Required: true,
-										ForceNew: true,
 									},
 									"default_value": {
 										Type:     schema.TypeString,
 										Required: true,
-										ForceNew: true,
 									},
 								},
 							},
@@ -85,34 +98,28 @@ func ResourceFeature() *schema.Resource {
 			"variations": {
 				Type:     schema.TypeList,
 				Optional: true,
-				ForceNew: true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"variation": {
-							Type:     schema.TypeList,
+							Type:     schema.TypeSet,
 							Required: true,
-							ForceNew: true,
 							Elem: &schema.Resource{
 								Schema: map[string]*schema.Schema{
 									"key": {
 										Type:     schema.TypeString,
 										Required: true,
-										ForceNew: true,
 									},
 									"name": {
 										Type:     schema.TypeString,
 										Optional: true,
-										ForceNew: true,
 									},
 									"description": {
 										Type:     schema.TypeString,
 										Optional: true,
-										ForceNew: true,
 									},
 									"variables": {
 										Type:     schema.TypeMap,
 										Optional: true,
-										ForceNew: true,
 									},
 								},
 							},
@@ -120,6 +127,30 @@ func ResourceFeature() *schema.Resource {
 					},
 				},
 			},
+			"archive_on_destroy": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Archive the flag on destroy instead of deleting it, keeping its history in Optimizely",
+			},
+			"environment": {
+				Type:        schema.TypeSet,
+				Optional:    true,
+				Description: "Whether the flag is enabled in an environment, by default it is in the environments it has rules in",
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"key": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"enabled": {
+							Type:     schema.TypeBool,
+							Optional: true,
+							Default:  true,
+						},
+					},
+				},
+			},
 			"rules": {
 				Type:     schema.TypeList,
 				Optional: true,
@@ -133,7 +164,6 @@ func ResourceFeature() *schema.Resource {
 									"key": {
 										Type:     schema.TypeString,
 										Required: true,
-										ForceNew: true,
 									},
 									"environments": {
 										Type:     schema.TypeList,
@@ -144,18 +174,81 @@ func ResourceFeature() *schema.Resource {
 									},
 									"audience": {
 										Type:     schema.TypeList,
-										Required: true,
+										Optional: true,
 										Elem: &schema.Schema{
 											Type: schema.TypeString,
 										},
 									},
+									"audience_conditions": {
+										Type:     schema.TypeList,
+										Optional: true,
+										MaxItems: 1,
+										Elem:     audienceConditionGroup(maxConditionDepth),
+									},
 									"percentage_included": {
 										Type:     schema.TypeInt,
 										Required: true,
 									},
+									"type": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      RuleTypeTargetedDelivery,
+										ValidateFunc: validation.StringInSlice([]string{RuleTypeTargetedDelivery, RuleTypeABTest}, false),
+									},
 									"deliver": {
 										Type:     schema.TypeString,
-										Required: true,
+										Optional: true,
+									},
+									"variation": {
+										Type:     schema.TypeSet,
+										Optional: true,
+										Elem: &schema.Resource{
+											Schema: map[string]*schema.Schema{
+												"key": {
+													Type:     schema.TypeString,
+													Required: true,
+												},
+												"weight": {
+													Type:         schema.TypeInt,
+													Required:     true,
+													ValidateFunc: validation.IntBetween(0, 100),
+												},
+											},
+										},
+									},
+									"metric": {
+										Type:     schema.TypeList,
+										Optional: true,
+										Elem: &schema.Resource{
+											Schema: map[string]*schema.Schema{
+												"event_id": {
+													Type:     schema.TypeInt,
+													Required: true,
+												},
+												"aggregator": {
+													Type:         schema.TypeString,
+													Optional:     true,
+													Default:      "unique",
+													ValidateFunc: validation.StringInSlice([]string{"unique", "count", "sum"}, false),
+												},
+												"winning_direction": {
+													Type:         schema.TypeString,
+													Optional:     true,
+													Default:      "increasing",
+													ValidateFunc: validation.StringInSlice([]string{"increasing", "decreasing"}, false),
+												},
+												"primary": {
+													Type:     schema.TypeBool,
+													Computed: true,
+												},
+											},
+										},
+									},
+									"distribution_mode": {
+										Type:         schema.TypeString,
+										Optional:     true,
+										Default:      "manual",
+										ValidateFunc: validation.StringInSlice([]string{"manual", "stats_accel"}, false),
 									},
 								},
 							},
@@ -164,182 +257,427 @@ func ResourceFeature() *schema.Resource {
 				},
 			},
 		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+		CustomizeDiff: customdiff.All(
+			project.SetDefaultProject,
+			validateRules,
+		),
 		CreateContext: resourceFeatureCreate,
 		ReadContext:   resourceFeatureRead,
 		DeleteContext: resourceFeatureDelete,
 		UpdateContext: resourceFeatureUpdate,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceFeatureImport,
+		},
 	}
 }
 
+// flagId builds the ID of a flag resource, <project>/<key>, which is enough
+// to address the flag without any configuration.
+func flagId(projectId int, key string) string {
+	return fmt.Sprintf("%d/%s", projectId, key)
+}
+
+func splitFlagId(id string) (int, string, error) {
+	parts := strings.SplitN(id, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return 0, "", fmt.Errorf("expected a flag ID as <project_id>/<flag_key>, got %q", id)
+	}
+
+	projectId, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", fmt.Errorf("expected a flag ID as <project_id>/<flag_key>, got %q: %w", id, err)
+	}
+
+	return projectId, parts[1], nil
+}
+
+// flagProjectAndKey returns the project and key the resource addresses.
+// Resources created before IDs were <project>/<key> hold the numeric flag ID,
+// those fall back to the project and key in state.
+func flagProjectAndKey(d *schema.ResourceData) (int, string, error) {
+	if _, err := strconv.ParseInt(d.Id(), 10, 64); err == nil {
+		return d.Get("project").(int), d.Get("key").(string), nil
+	}
+
+	return splitFlagId(d.Id())
+}
+
 func parseFlag(d *schema.ResourceData) Flag {
-	variablesSchema := parseVariableSchema(d)
-	variations := parseVariation(d)
-	envs := parseEnvironment(d)
+	return flagFromValues(d.Get)
+}
+
+// parseOldFlag parses the flag as it was before the changes being applied.
+func parseOldFlag(d *schema.ResourceData) Flag {
+	return flagFromValues(func(key string) interface{} {
+		old, _ := d.GetChange(key)
+		return old
+	})
+}
 
+func flagFromValues(get func(string) interface{}) Flag {
 	return Flag{
-		ProjectId:    d.Get("project").(int),
-		Name:         d.Get("name").(string),
-		Description:  d.Get("description").(string),
-		Key:          d.Get("key").(string),
+		ProjectId:    get("project").(int),
+		Name:         get("name").(string),
+		Description:  get("description").(string),
+		Key:          get("key").(string),
 		Archived:     false,
-		Variables:    variablesSchema,
-		Variations:   variations,
-		Environments: envs,
+		Variables:    parseVariableSchema(get("variable_schema").([]interface{})),
+		Variations:   parseVariation(get("variations").([]interface{})),
+		Environments: parseEnvironment(get("rules").([]interface{})),
 	}
 }
 
+// setElements returns the blocks of a set attribute. A set changed during an
+// update may hold an empty element left behind by the SDK, blocks without a
+// key are skipped.
+func setElements(raw interface{}) []map[string]interface{} {
+	set, ok := raw.(*schema.Set)
+	if !ok {
+		return nil
+	}
+
+	var elements []map[string]interface{}
+	for _, e := range set.List() {
+		eMap := e.(map[string]interface{})
+		if key, _ := eMap["key"].(string); key != "" {
+			elements = append(elements, eMap)
+		}
+	}
+
+	return elements
+}
+
 func resourceFeatureCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
 	client := m.(FlagClient)
 
 	flag := parseFlag(d)
+	flag.ProjectId = d.Get("project").(int)
+	d.Set("project", flag.ProjectId)
 
-	featResp, err := client.CreateFlag(flag)
+	featResp, err := client.CreateFlag(ctx, flag)
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to create flag in Optimizely: %+v", err),
-		})
-
-		return diags
+		return apierror.Diagnostics("Failed to create flag in Optimizely", err, flagFields)
 	}
 
+	// the flag exists from here on, a later failure leaves it tainted in
+	// state instead of orphaned in Optimizely
+	d.SetId(flagId(flag.ProjectId, featResp.Key))
+
 	for _, variation := range flag.Variations {
-		err := client.CreateVariation(flag, variation)
+		err := client.CreateVariation(ctx, flag, variation)
 		if err != nil {
-			diags = append(diags, diag.Diagnostic{
-				Severity: diag.Error,
-				Summary:  fmt.Sprintf("Failed to create flag variations in Optimizely: %+v", err),
-			})
-
-			return diags
+			return apierror.Diagnostics("Failed to create flag variations in Optimizely", err, flagFields)
 		}
 	}
 
-	err = client.CreateRuleset(flag)
+	err = client.CreateRuleset(ctx, flag)
+	if err != nil {
+		return apierror.Diagnostics("Failed to create ruleset in Optimizely", err, flagFields)
+	}
+
+	err = toggleRulesets(ctx, client, flag, rulesetStates(flag, parseEnvironmentStates(d.Get("environment"))))
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to create ruleset in Optimizely: %+v", err),
+		return apierror.Diagnostics("Failed to enable ruleset in Optimizely", err, flagFields)
+	}
+
+	return resourceFeatureRead(ctx, d, m)
+}
+
+func resourceFeatureRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	client := m.(FlagClient)
+
+	projectId, key, err := flagProjectAndKey(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	flagResp, envKeys, err := readFlag(ctx, client, projectId, key)
+	if apierror.IsNotFound(err) {
+		tflog.Warn(ctx, "Flag not found in Optimizely, removing it from state", map[string]interface{}{
+			"project": projectId,
+			"key":     key,
 		})
 
+		d.SetId("")
 		return diags
 	}
-
-	err = client.EnableRuleset(flag)
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to enable ruleset in Optimizely: %+v", err),
+		return apierror.Diagnostics("Failed to read flag from Optimizely", err, flagFields)
+	}
+
+	if flagResp.Archived {
+		tflog.Warn(ctx, "Flag archived in Optimizely, removing it from state", map[string]interface{}{
+			"project": projectId,
+			"key":     key,
 		})
 
+		d.SetId("")
 		return diags
 	}
 
-	d.SetId(strconv.FormatInt(featResp.ID, 10))
-	// return resourceFeatureRead(ctx, d, m)
+	d.SetId(flagId(projectId, key))
+	setFlagState(d, flagResp, envKeys)
+
+	// imported flags, and states written before archive_on_destroy existed,
+	// have no value for it
+	if state := d.GetRawState(); !state.IsNull() && state.GetAttr("archive_on_destroy").IsNull() {
+		d.Set("archive_on_destroy", false)
+	}
+
 	return diags
 }
 
-func resourceFeatureRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
-	client := m.(FlagClient)
+// toggleRulesets enables or disables the ruleset of each environment in
+// states.
+func toggleRulesets(ctx context.Context, client FlagClient, flag Flag, states map[string]bool) error {
+	enabled := Flag{ProjectId: flag.ProjectId, Key: flag.Key, Environments: make(map[string]FeatureEnvironment)}
+	disabled := Flag{ProjectId: flag.ProjectId, Key: flag.Key, Environments: make(map[string]FeatureEnvironment)}
+
+	for env, state := range states {
+		if state {
+			enabled.Environments[env] = FeatureEnvironment{}
+		} else {
+			disabled.Environments[env] = FeatureEnvironment{}
+		}
+	}
 
-	flag := parseFlag(d)
+	err := client.DisableRuleset(ctx, disabled)
+	if err != nil {
+		return err
+	}
 
-	flagResp, err := client.GetFlag(flag.ProjectId, flag.Key)
+	return client.EnableRuleset(ctx, enabled)
+}
+
+// readFlag fetches a flag along with its variations and the rules of every
+// environment of its project.
+func readFlag(ctx context.Context, client FlagClient, projectId int, key string) (Flag, []string, error) {
+	flag, err := client.GetFlag(ctx, projectId, key)
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed fetch flag from Optimizely: %+v", err),
-		})
+		return flag, nil, err
+	}
+	flag.ProjectId = projectId
 
-		return diags
+	flag.Variations, err = client.GetVariation(ctx, projectId, key)
+	if err != nil {
+		return flag, nil, err
 	}
 
-	flagResp.Variations, err = client.GetVariation(flag.ProjectId, flag.Key)
+	envKeys, err := client.ListEnvironmentKeys(ctx, projectId)
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed fetch flag from Optimizely; failed to fetch variations: %+v", err),
-		})
+		return flag, nil, err
+	}
 
-		return diags
+	flag.Environments = make(map[string]FeatureEnvironment)
+	for _, env := range envKeys {
+		flag.Environments[env] = FeatureEnvironment{}
 	}
 
-	flagResp.Environments, err = client.GetRuleset(flag)
+	flag.Environments, err = client.GetRuleset(ctx, flag)
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed fetch flag from Optimizely; failed to fetch environment rules: %+v", err),
-		})
+		return flag, nil, err
+	}
 
-		return diags
+	if err := checkConditionDepth(flag.Environments); err != nil {
+		return flag, nil, err
 	}
 
-	flagRespJson, _ := json.Marshal(flagResp)
-	fmt.Printf("Read Flag: %s", flagRespJson)
+	return flag, envKeys, nil
+}
 
-	return diags
+func setFlagState(d *schema.ResourceData, flag Flag, envKeys []string) {
+	d.Set("project", flag.ProjectId)
+	d.Set("key", flag.Key)
+	d.Set("name", flag.Name)
+	d.Set("description", flag.Description)
+	d.Set("variable_schema", flattenVariableSchema(flag.Variables))
+	d.Set("variations", flattenVariations(flag.Variations))
+	d.Set("rules", flattenRules(flag.Environments, envKeys, d.Get("rules").([]interface{})))
+	d.Set("environment", flattenEnvironments(flag.Environments, envKeys, d.Get("environment")))
+}
+
+func resourceFeatureImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	client := m.(FlagClient)
+
+	projectId, key, err := splitFlagId(d.Id())
+	if err != nil {
+		return nil, err
+	}
+
+	flag, envKeys, err := readFlag(ctx, client, projectId, key)
+	if err != nil {
+		return nil, err
+	}
+
+	setFlagState(d, flag, envKeys)
+
+	return []*schema.ResourceData{d}, nil
 }
 
 func resourceFeatureDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 	client := m.(FlagClient)
 
-	flag := parseFlag(d)
+	projectId, key, err := flagProjectAndKey(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
 
-	fmt.Printf("DELETE %s \n", flag.Key)
+	tflog.Debug(ctx, "Deleting flag", map[string]interface{}{
+		"project": projectId,
+		"key":     key,
+	})
 
-	err := client.DisableRuleset(flag)
+	// the flag may be enabled in environments it has no rules in anymore, or
+	// that were enabled in the UI, so every environment is checked
+	envKeys, err := client.ListEnvironmentKeys(ctx, projectId)
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to disable ruleset while deleting flag in Optimizely: %+v", err),
-		})
+		return apierror.Diagnostics("Failed to list environments while deleting flag in Optimizely", err, flagFields)
+	}
+
+	flag := Flag{ProjectId: projectId, Key: key, Environments: make(map[string]FeatureEnvironment)}
+	for _, env := range envKeys {
+		flag.Environments[env] = FeatureEnvironment{}
+	}
 
+	envs, err := client.GetRuleset(ctx, flag)
+	if apierror.IsNotFound(err) {
 		return diags
 	}
+	if err != nil {
+		return apierror.Diagnostics("Failed to read ruleset while deleting flag in Optimizely", err, flagFields)
+	}
+
+	states := make(map[string]bool)
+	for env, flagEnv := range envs {
+		if flagEnv.Enabled {
+			states[env] = false
+		}
+	}
 
-	err = client.DeleteFlag(flag.ProjectId, flag.Key)
+	err = toggleRulesets(ctx, client, flag, states)
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to delete flag in Optimizely: %+v", err),
-		})
+		return apierror.Diagnostics("Failed to disable ruleset while deleting flag in Optimizely", err, flagFields)
+	}
+
+	if d.Get("archive_on_destroy").(bool) {
+		err = client.ArchiveFlag(ctx, projectId, key)
+		if err != nil {
+			return apierror.Diagnostics("Failed to archive flag in Optimizely", err, flagFields)
+		}
 
 		return diags
 	}
 
+	err = client.DeleteFlag(ctx, projectId, key)
+	if err != nil {
+		return apierror.Diagnostics("Failed to delete flag in Optimizely", err, flagFields)
+	}
+
 	return diags
 }
 
 func resourceFeatureUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
 	client := m.(FlagClient)
 
+	oldFlag := parseOldFlag(d)
 	flag := parseFlag(d)
 
-	err := client.UpdateRuleset(flag)
+	// removed variables are kept until the variations carrying a value for
+	// them are updated or archived, Optimizely refuses to remove a variable
+	// still in use
+	keptFlag := flag
+	removedVariables := false
+	for key, variable := range oldFlag.Variables {
+		if _, ok := flag.Variables[key]; !ok {
+			if !removedVariables {
+				keptFlag.Variables = make(map[string]VariableSchema)
+				for k, v := range flag.Variables {
+					keptFlag.Variables[k] = v
+				}
+				removedVariables = true
+			}
+			keptFlag.Variables[key] = variable
+		}
+	}
+
+	if d.HasChanges("name", "description", "variable_schema") {
+		err := client.UpdateFlag(ctx, oldFlag, keptFlag)
+		if err != nil {
+			return apierror.Diagnostics("Failed to update flag in Optimizely", err, flagFields)
+		}
+	}
+
+	// variations are created and updated before the rules, which may serve
+	// them, and archived after the rules stopped serving them
+	var archived []string
+	if d.HasChange("variations") {
+		oldVariations := make(map[string]Variation)
+		for _, variation := range oldFlag.Variations {
+			oldVariations[variation.Key] = variation
+		}
+
+		for _, variation := range flag.Variations {
+			oldVariation, ok := oldVariations[variation.Key]
+			delete(oldVariations, variation.Key)
+
+			var err error
+			switch {
+			case !ok:
+				err = client.CreateVariation(ctx, flag, variation)
+			case !reflect.DeepEqual(oldVariation, variation):
+				err = client.UpdateVariation(ctx, flag, oldVariation, variation)
+			}
+			if err != nil {
+				return apierror.Diagnostics("Failed to update flag variations in Optimizely", err, flagFields)
+			}
+		}
+
+		for key := range oldVariations {
+			archived = append(archived, key)
+		}
+		sort.Strings(archived)
+	}
+
+	err := client.UpdateRuleset(ctx, oldFlag, flag)
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to create ruleset in Optimizely: %+v", err),
-		})
+		return apierror.Diagnostics("Failed to update ruleset in Optimizely", err, flagFields)
+	}
 
-		return diags
+	// environments that no longer have rules nor an environment block
+	// aren't managed anymore, and are disabled so the flag can be deleted
+	oldEnv, _ := d.GetChange("environment")
+	states := rulesetStates(flag, parseEnvironmentStates(d.Get("environment")))
+	for env := range rulesetStates(oldFlag, parseEnvironmentStates(oldEnv)) {
+		if _, ok := states[env]; !ok {
+			states[env] = false
+		}
 	}
 
-	err = client.EnableRuleset(flag)
+	err = toggleRulesets(ctx, client, flag, states)
 	if err != nil {
-		diags = append(diags, diag.Diagnostic{
-			Severity: diag.Error,
-			Summary:  fmt.Sprintf("Failed to enable ruleset in Optimizely: %+v", err),
-		})
+		return apierror.Diagnostics("Failed to enable or disable ruleset in Optimizely", err, flagFields)
+	}
 
-		return diags
+	if len(archived) > 0 {
+		err = client.ArchiveVariations(ctx, flag, archived)
+		if err != nil {
+			return apierror.Diagnostics("Failed to archive flag variations in Optimizely", err, flagFields)
+		}
 	}
 
-	// return resourceFeatureRead(ctx, d, m)
-	return diags
+	if removedVariables {
+		err := client.UpdateFlag(ctx, keptFlag, flag)
+		if err != nil {
+			return apierror.Diagnostics("Failed to update flag in Optimizely", err, flagFields)
+		}
+	}
+
+	return resourceFeatureRead(ctx, d, m)
 }
@@ -1,22 +1,16 @@
 package flag
 
-import "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-
 type VariableSchema struct {
 	DefaultValue string `json:"default_value"`
 	Key          string `json:"key"`
 	Type         string `json:"type"`
 }
 
-func parseVariableSchema(d *schema.ResourceData) map[string]VariableSchema {
+func parseVariableSchema(variableSchemaList []interface{}) map[string]VariableSchema {
 	variableSchemaByKey := make(map[string]VariableSchema)
-	variableSchemaList := d.Get("variable_schema").([]interface{})
 
 	for _, variable := range variableSchemaList {
-		vars := variable.(map[string]interface{})["variable"]
-		for _, v := range vars.([]interface{}) {
-			vMap := v.(map[string]interface{})
-
+		for _, vMap := range setElements(variable.(map[string]interface{})["variable"]) {
 			key := vMap["key"].(string)
 			variableSchema := VariableSchema{
 				Key:          key,
@@ -29,3 +23,22 @@ func parseVariableSchema(d *schema.ResourceData) map[string]VariableSchema {
 
 	return variableSchemaByKey
 }
+
+func flattenVariableSchema(variables map[string]VariableSchema) []interface{} {
+	if len(variables) == 0 {
+		return []interface{}{}
+	}
+
+	var vars []interface{}
+	for key, variable := range variables {
+		vars = append(vars, map[string]interface{}{
+			"key":           key,
+			"type":          variable.Type,
+			"default_value": variable.DefaultValue,
+		})
+	}
+
+	return []interface{}{
+		map[string]interface{}{"variable": vars},
+	}
+}
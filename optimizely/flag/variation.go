@@ -1,20 +1,19 @@
 package flag
 
-import "github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+import "fmt"
 
 type Variation struct {
 	Key         string                 `json:"key"`
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
 	Variables   map[string]interface{} `json:"variables"`
+	Archived    bool                   `json:"archived,omitempty"`
 }
 
-func parseVariation(d *schema.ResourceData) []Variation {
+func parseVariation(raw []interface{}) []Variation {
 	var variations []Variation
-	for _, variationMap := range d.Get("variations").([]interface{}) {
-		vars := variationMap.(map[string]interface{})["variation"]
-		for _, v := range vars.([]interface{}) {
-			vMap := v.(map[string]interface{})
+	for _, variationMap := range raw {
+		for _, vMap := range setElements(variationMap.(map[string]interface{})["variation"]) {
 			vSchema := Variation{
 				Key:         vMap["key"].(string),
 				Name:        vMap["name"].(string),
@@ -27,3 +26,41 @@ func parseVariation(d *schema.ResourceData) []Variation {
 
 	return variations
 }
+
+// defaultVariations are created by Optimizely along with every flag, they
+// aren't declared in the variations block. Archived variations are left out
+// too.
+var defaultVariations = map[string]bool{"off": true, "on": true}
+
+func flattenVariations(variations []Variation) []interface{} {
+	var vars []interface{}
+	for _, variation := range variations {
+		if defaultVariations[variation.Key] || variation.Archived {
+			continue
+		}
+
+		variables := make(map[string]interface{})
+		for key, value := range variation.Variables {
+			// the API wraps each variable value as {"value": ...}
+			if wrapped, ok := value.(map[string]interface{}); ok {
+				value = wrapped["value"]
+			}
+			variables[key] = fmt.Sprint(value)
+		}
+
+		vars = append(vars, map[string]interface{}{
+			"key":         variation.Key,
+			"name":        variation.Name,
+			"description": variation.Description,
+			"variables":   variables,
+		})
+	}
+
+	if len(vars) == 0 {
+		return []interface{}{}
+	}
+
+	return []interface{}{
+		map[string]interface{}{"variation": vars},
+	}
+}
@@ -0,0 +1,166 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type patchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// applyPatch applies RFC 6902 add, replace and remove operations to a copy
+// of doc, so a failing operation leaves doc untouched.
+func applyPatch(doc interface{}, ops []patchOp) (interface{}, error) {
+	patched, err := deepCopy(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range ops {
+		tokens, err := parsePointer(op.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(tokens) == 0 {
+			return nil, fmt.Errorf("%s of the whole document is not supported", op.Op)
+		}
+
+		switch op.Op {
+		case "add", "replace", "remove":
+		default:
+			return nil, fmt.Errorf("unsupported operation %q", op.Op)
+		}
+
+		value, err := deepCopy(op.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		patched, err = patchNode(patched, tokens, op.Op, value)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.Op, op.Path, err)
+		}
+	}
+
+	return patched, nil
+}
+
+func patchNode(node interface{}, tokens []string, op string, value interface{}) (interface{}, error) {
+	token := tokens[0]
+
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(tokens) > 1 {
+			child, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("path not found")
+			}
+
+			patched, err := patchNode(child, tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+
+			n[token] = patched
+			return n, nil
+		}
+
+		_, exists := n[token]
+		switch op {
+		case "add":
+			n[token] = value
+		case "replace":
+			if !exists {
+				return nil, fmt.Errorf("path not found")
+			}
+			n[token] = value
+		case "remove":
+			if !exists {
+				return nil, fmt.Errorf("path not found")
+			}
+			delete(n, token)
+		}
+		return n, nil
+
+	case []interface{}:
+		if len(tokens) == 1 && op == "add" && token == "-" {
+			return append(n, value), nil
+		}
+
+		index, err := strconv.Atoi(token)
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("invalid array index %q", token)
+		}
+
+		if len(tokens) > 1 {
+			if index >= len(n) {
+				return nil, fmt.Errorf("path not found")
+			}
+
+			patched, err := patchNode(n[index], tokens[1:], op, value)
+			if err != nil {
+				return nil, err
+			}
+
+			n[index] = patched
+			return n, nil
+		}
+
+		switch op {
+		case "add":
+			if index > len(n) {
+				return nil, fmt.Errorf("array index %d out of bounds", index)
+			}
+			n = append(n, nil)
+			copy(n[index+1:], n[index:])
+			n[index] = value
+		case "replace":
+			if index >= len(n) {
+				return nil, fmt.Errorf("array index %d out of bounds", index)
+			}
+			n[index] = value
+		case "remove":
+			if index >= len(n) {
+				return nil, fmt.Errorf("array index %d out of bounds", index)
+			}
+			n = append(n[:index], n[index+1:]...)
+		}
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("path not found")
+}
+
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+	}
+
+	return tokens, nil
+}
+
+func deepCopy(value interface{}) (interface{}, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var copied interface{}
+	err = json.Unmarshal(raw, &copied)
+	return copied, err
+}
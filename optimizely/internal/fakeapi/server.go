@@ -0,0 +1,878 @@
+// Package fakeapi is an in-memory stand-in for the parts of the Optimizely
+// REST v2 and Flags v1 APIs used by the provider, so the acceptance tests can
+// run without credentials.
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultToken = "fake-optimizely-token"
+
+type Server struct {
+	*httptest.Server
+
+	Token string
+
+	mu           sync.Mutex
+	nextID       int64
+	environments []string
+	projects     map[int64]map[string]interface{}
+	audiences    map[int64]map[string]interface{}
+	flags        map[int]map[string]*flagState
+}
+
+type flagState struct {
+	flag       map[string]interface{}
+	variations map[string]map[string]interface{}
+	rulesets   map[string]interface{}
+}
+
+// NewServer starts a fake API where every project has the given
+// environments, development and production when none are given.
+func NewServer(environments ...string) *Server {
+	if len(environments) == 0 {
+		environments = []string{"development", "production"}
+	}
+
+	s := &Server{
+		Token:        DefaultToken,
+		nextID:       1000,
+		environments: environments,
+		projects:     make(map[int64]map[string]interface{}),
+		audiences:    make(map[int64]map[string]interface{}),
+		flags:        make(map[int]map[string]*flagState),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+func (s *Server) RestAPI() string {
+	return s.URL + "/v2"
+}
+
+func (s *Server) FlagsAPI() string {
+	return s.URL + "/flags/v1"
+}
+
+// AddProject adds a Feature Experimentation project to the account, for
+// tests reading projects they don't create.
+func (s *Server) AddProject(id int64, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.projects[id] = newProject(id, map[string]interface{}{"name": name})
+}
+
+func newProject(id int64, fields map[string]interface{}) map[string]interface{} {
+	project := map[string]interface{}{
+		"id":          id,
+		"account_id":  1,
+		"name":        "",
+		"description": "",
+		"platform":    "custom",
+		"status":      "active",
+		"created":     time.Now().UTC().Format(time.RFC3339),
+	}
+	mergeFields(project, fields)
+
+	// projects on the custom platform are Feature Experimentation ones
+	project["is_flags_enabled"] = project["platform"] == "custom"
+
+	return project
+}
+
+// Ruleset returns the current ruleset of a flag in an environment, or nil.
+func (s *Server) Ruleset(projectId int, flagKey, env string) map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	flag, ok := s.flags[projectId][flagKey]
+	if !ok {
+		return nil
+	}
+
+	ruleset, _ := deepCopy(flag.rulesets[env])
+	rulesetMap, _ := ruleset.(map[string]interface{})
+	return rulesetMap
+}
+
+type apiError struct {
+	status  int
+	code    string
+	message string
+	field   string
+}
+
+func (e apiError) Error() string {
+	return e.message
+}
+
+func errorf(status int, field, format string, args ...interface{}) apiError {
+	return apiError{
+		status:  status,
+		code:    strings.ToUpper(strings.ReplaceAll(http.StatusText(status), " ", "_")),
+		message: fmt.Sprintf(format, args...),
+		field:   field,
+	}
+}
+
+var errNotFound = errorf(http.StatusNotFound, "", "resource not found")
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+s.Token {
+		s.writeError(w, errorf(http.StatusUnauthorized, "", "invalid or missing token"))
+		return
+	}
+
+	var body interface{}
+	if r.Body != nil && r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			s.writeError(w, errorf(http.StatusBadRequest, "", "malformed JSON body: %s", err))
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	var resp interface{}
+	var err error
+
+	switch {
+	case len(segments) >= 2 && segments[0] == "v2" && segments[1] == "audiences":
+		resp, err = s.serveAudiences(r.Method, segments[2:], body)
+	case len(segments) >= 2 && segments[0] == "v2" && segments[1] == "projects":
+		resp, err = s.serveProjects(r.Method, segments[2:], r.URL.Query(), body)
+	case len(segments) == 2 && segments[0] == "v2" && segments[1] == "environments" && r.Method == http.MethodGet:
+		projectId, convErr := strconv.Atoi(r.URL.Query().Get("project_id"))
+		if convErr != nil {
+			err = errorf(http.StatusBadRequest, "project_id", "project_id is required")
+			break
+		}
+		resp = paginate(s.environmentList(projectId), r.URL.Query())
+	case len(segments) >= 4 && segments[0] == "flags" && segments[1] == "v1" && segments[2] == "projects":
+		projectId, convErr := strconv.Atoi(segments[3])
+		if convErr != nil {
+			err = errNotFound
+			break
+		}
+		resp, err = s.serveProject(r.Method, projectId, segments[4:], r.URL.Query(), body)
+	default:
+		err = errNotFound
+	}
+
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if resp == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodPost {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	apiErr, ok := err.(apiError)
+	if !ok {
+		apiErr = errorf(http.StatusInternalServerError, "", "%s", err)
+	}
+
+	s.nextID++
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":    apiErr.code,
+		"message": apiErr.message,
+		"field":   apiErr.field,
+		"uuid":    fmt.Sprintf("fake-request-%d", s.nextID),
+	})
+}
+
+func (s *Server) serveAudiences(method string, segments []string, body interface{}) (interface{}, error) {
+	fields, _ := body.(map[string]interface{})
+
+	if len(segments) == 0 {
+		if method != http.MethodPost {
+			return nil, errorf(http.StatusMethodNotAllowed, "", "method not allowed")
+		}
+
+		if projectId, ok := fields["project_id"].(float64); !ok || projectId <= 0 {
+			return nil, errorf(http.StatusBadRequest, "project_id", "project_id is required")
+		}
+
+		if err := validateConditions(fields); err != nil {
+			return nil, err
+		}
+
+		s.nextID++
+		audience := map[string]interface{}{
+			"id":          s.nextID,
+			"name":        "",
+			"description": "",
+			"conditions":  "[\"and\"]",
+			"archived":    false,
+			"created":     time.Now().UTC().Format(time.RFC3339),
+		}
+		mergeFields(audience, fields)
+		s.audiences[s.nextID] = audience
+
+		return audience, nil
+	}
+
+	id, err := strconv.ParseInt(segments[0], 10, 64)
+	if err != nil || len(segments) > 1 {
+		return nil, errNotFound
+	}
+
+	audience, ok := s.audiences[id]
+	if !ok {
+		return nil, errNotFound
+	}
+
+	switch method {
+	case http.MethodGet:
+		return audience, nil
+	case http.MethodPatch:
+		if err := validateConditions(fields); err != nil {
+			return nil, err
+		}
+		mergeFields(audience, fields)
+		return audience, nil
+	}
+
+	return nil, errorf(http.StatusMethodNotAllowed, "", "method not allowed")
+}
+
+func validateConditions(fields map[string]interface{}) error {
+	conditions, ok := fields["conditions"]
+	if !ok {
+		return nil
+	}
+
+	conditionsStr, ok := conditions.(string)
+	if !ok || !json.Valid([]byte(conditionsStr)) {
+		return errorf(http.StatusBadRequest, "conditions", "conditions must be a JSON encoded string")
+	}
+
+	return nil
+}
+
+func mergeFields(dst, src map[string]interface{}) {
+	for key, value := range src {
+		if key == "id" {
+			continue
+		}
+		dst[key] = value
+	}
+	dst["last_modified"] = time.Now().UTC().Format(time.RFC3339)
+}
+
+func (s *Server) serveProjects(method string, segments []string, query url.Values, body interface{}) (interface{}, error) {
+	fields, _ := body.(map[string]interface{})
+
+	if len(segments) == 0 {
+		switch method {
+		case http.MethodGet:
+			return s.listProjects(query), nil
+		case http.MethodPost:
+			if name, _ := fields["name"].(string); strings.TrimSpace(name) == "" {
+				return nil, errorf(http.StatusBadRequest, "name", "name is required")
+			}
+
+			if err := validateProject(fields); err != nil {
+				return nil, err
+			}
+
+			s.nextID++
+			project := newProject(s.nextID, fields)
+			s.projects[s.nextID] = project
+
+			return project, nil
+		}
+
+		return nil, errorf(http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+
+	id, err := strconv.ParseInt(segments[0], 10, 64)
+	if err != nil || len(segments) > 1 {
+		return nil, errNotFound
+	}
+
+	project, ok := s.projects[id]
+	if !ok {
+		return nil, errNotFound
+	}
+
+	switch method {
+	case http.MethodGet:
+		return project, nil
+	case http.MethodPatch:
+		if _, ok := fields["platform"]; ok {
+			return nil, errorf(http.StatusBadRequest, "platform", "platform can't be changed")
+		}
+
+		if err := validateProject(fields); err != nil {
+			return nil, err
+		}
+
+		mergeFields(project, fields)
+		return project, nil
+	}
+
+	return nil, errorf(http.StatusMethodNotAllowed, "", "method not allowed")
+}
+
+func validateProject(fields map[string]interface{}) error {
+	if platform, ok := fields["platform"]; ok && platform != "web" && platform != "custom" {
+		return errorf(http.StatusBadRequest, "platform", "platform must be web or custom")
+	}
+
+	if status, ok := fields["status"]; ok && status != "active" && status != "archived" {
+		return errorf(http.StatusBadRequest, "status", "status must be active or archived")
+	}
+
+	return nil
+}
+
+// listProjects serves a page of projects in ID order, like the REST API
+// paginated by page and per_page.
+func (s *Server) listProjects(query url.Values) []interface{} {
+	ids := make([]int64, 0, len(s.projects))
+	for id := range s.projects {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+
+	projects := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		projects = append(projects, s.projects[id])
+	}
+
+	return paginate(projects, query)
+}
+
+// paginate returns the page of items the page and per_page parameters ask
+// for, 25 items a page by default like the REST API.
+func paginate(items []interface{}, query url.Values) []interface{} {
+	page, err := strconv.Atoi(query.Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = 25
+	}
+
+	pageItems := []interface{}{}
+	for i := (page - 1) * perPage; i < len(items) && i < page*perPage; i++ {
+		pageItems = append(pageItems, items[i])
+	}
+
+	return pageItems
+}
+
+func (s *Server) serveProject(method string, projectId int, segments []string, query url.Values, body interface{}) (interface{}, error) {
+	if len(segments) == 1 && segments[0] == "environments" && method == http.MethodGet {
+		return s.environmentPage(projectId, query), nil
+	}
+
+	if len(segments) == 0 || segments[0] != "flags" {
+		return nil, errNotFound
+	}
+
+	flags, ok := s.flags[projectId]
+	if !ok {
+		flags = make(map[string]*flagState)
+		s.flags[projectId] = flags
+	}
+
+	if len(segments) == 1 {
+		switch method {
+		case http.MethodGet:
+			return s.listFlags(flags), nil
+		case http.MethodPost:
+			return s.createFlag(projectId, flags, body)
+		}
+		return nil, errorf(http.StatusMethodNotAllowed, "", "method not allowed")
+	}
+
+	if len(segments) == 2 && segments[1] == "archived" {
+		if method != http.MethodPost {
+			return nil, errorf(http.StatusMethodNotAllowed, "", "method not allowed")
+		}
+		return archiveFlags(flags, body)
+	}
+
+	flag, ok := flags[segments[1]]
+	if !ok {
+		return nil, errNotFound
+	}
+
+	switch {
+	case len(segments) == 2:
+		switch method {
+		case http.MethodGet:
+			return flag.flag, nil
+		case http.MethodDelete:
+			for env, ruleset := range flag.rulesets {
+				if enabled, _ := ruleset.(map[string]interface{})["enabled"].(bool); enabled {
+					return nil, errorf(http.StatusConflict, "", "flag %s is enabled in environment %s, disable it before deleting", segments[1], env)
+				}
+			}
+			delete(flags, segments[1])
+			return nil, nil
+		case http.MethodPatch:
+			return patchFlag(flag, body)
+		}
+
+	case len(segments) == 3 && segments[2] == "variations":
+		switch method {
+		case http.MethodGet:
+			return listVariations(flag), nil
+		case http.MethodPost:
+			return createVariation(flag, body)
+		}
+
+	case len(segments) == 4 && segments[2] == "variations" && segments[3] == "archived":
+		if method == http.MethodPost {
+			return archiveVariations(flag, body)
+		}
+
+	case len(segments) == 4 && segments[2] == "variations":
+		if method == http.MethodPatch {
+			return patchVariation(flag, segments[3], body)
+		}
+
+	case len(segments) >= 5 && segments[2] == "environments" && segments[4] == "ruleset":
+		ruleset, ok := flag.rulesets[segments[3]]
+		if !ok {
+			return nil, errNotFound
+		}
+
+		switch {
+		case len(segments) == 5 && method == http.MethodGet:
+			return ruleset, nil
+		case len(segments) == 5 && method == http.MethodPatch:
+			return patchRuleset(flag, segments[3], body)
+		case len(segments) == 6 && method == http.MethodPost && (segments[5] == "enabled" || segments[5] == "disabled"):
+			ruleset.(map[string]interface{})["enabled"] = segments[5] == "enabled"
+			return ruleset, nil
+		}
+
+	default:
+		return nil, errNotFound
+	}
+
+	return nil, errorf(http.StatusMethodNotAllowed, "", "method not allowed")
+}
+
+func (s *Server) listEnvironments(projectId int) map[string]interface{} {
+	items := []interface{}{}
+	for i, env := range s.environments {
+		items = append(items, map[string]interface{}{
+			"id":         projectId*100 + i + 1,
+			"key":        env,
+			"name":       env,
+			"archived":   false,
+			"priority":   i + 1,
+			"is_primary": i == len(s.environments)-1,
+		})
+	}
+
+	return map[string]interface{}{"items": items}
+}
+
+// environmentPage serves a page of the Flags API environment list, 10
+// environments unless per_page says otherwise. Like Optimizely, the page
+// links the next one with a next_url relative to the Flags API.
+func (s *Server) environmentPage(projectId int, query url.Values) map[string]interface{} {
+	page, err := strconv.Atoi(query.Get("page_token"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(query.Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = 10
+	}
+
+	items := s.listEnvironments(projectId)["items"].([]interface{})
+	resp := map[string]interface{}{
+		"items": paginate(items, url.Values{"page": {strconv.Itoa(page)}, "per_page": {strconv.Itoa(perPage)}}),
+	}
+
+	if page*perPage < len(items) {
+		next := url.Values{"page_token": {strconv.Itoa(page + 1)}, "per_page": {strconv.Itoa(perPage)}}
+		resp["next_url"] = fmt.Sprintf("/projects/%d/environments?%s", projectId, next.Encode())
+	}
+
+	return resp
+}
+
+// environmentList lists the environments the way the REST API does, as an
+// array of environments with their datafile.
+func (s *Server) environmentList(projectId int) []interface{} {
+	envs := []interface{}{}
+	for _, item := range s.listEnvironments(projectId)["items"].([]interface{}) {
+		env := item.(map[string]interface{})
+		sdkKey := fmt.Sprintf("fake-sdk-key-%d-%s", projectId, env["key"])
+
+		env["project_id"] = projectId
+		env["datafile"] = map[string]interface{}{
+			"id":      env["id"],
+			"sdk_key": sdkKey,
+			"url":     fmt.Sprintf("https://cdn.optimizely.com/datafiles/%s.json", sdkKey),
+		}
+		envs = append(envs, env)
+	}
+
+	return envs
+}
+
+func (s *Server) listFlags(flags map[string]*flagState) map[string]interface{} {
+	keys := make([]string, 0, len(flags))
+	for key := range flags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	items := []interface{}{}
+	for _, key := range keys {
+		items = append(items, flags[key].flag)
+	}
+
+	return map[string]interface{}{"items": items}
+}
+
+func (s *Server) createFlag(projectId int, flags map[string]*flagState, body interface{}) (interface{}, error) {
+	fields, _ := body.(map[string]interface{})
+
+	key, _ := fields["key"].(string)
+	if key == "" {
+		return nil, errorf(http.StatusBadRequest, "key", "key is required")
+	}
+
+	if _, exists := flags[key]; exists {
+		return nil, errorf(http.StatusConflict, "key", "flag %s already exists", key)
+	}
+
+	variableDefinitions, _ := fields["variable_definitions"].(map[string]interface{})
+	if variableDefinitions == nil {
+		variableDefinitions = map[string]interface{}{}
+	}
+
+	s.nextID++
+	flag := &flagState{
+		flag: map[string]interface{}{
+			"id":                   s.nextID,
+			"project_id":           projectId,
+			"key":                  key,
+			"name":                 fields["name"],
+			"description":          fields["description"],
+			"archived":             false,
+			"variable_definitions": variableDefinitions,
+		},
+		variations: make(map[string]map[string]interface{}),
+		rulesets:   make(map[string]interface{}),
+	}
+
+	// like Optimizely, every flag starts with an "off" and an "on" variation
+	for _, variation := range []string{"off", "on"} {
+		flag.variations[variation] = map[string]interface{}{
+			"key":       variation,
+			"name":      variation,
+			"archived":  false,
+			"variables": map[string]interface{}{},
+		}
+	}
+
+	for _, env := range s.environments {
+		flag.rulesets[env] = map[string]interface{}{
+			"enabled":               false,
+			"archived":              false,
+			"default_variation_key": "off",
+			"rules":                 map[string]interface{}{},
+			"rule_priorities":       []interface{}{},
+		}
+	}
+
+	flags[key] = flag
+
+	return flag.flag, nil
+}
+
+// archiveFlags archives the flags listed in keys, refusing, like deleting,
+// the ones still enabled in an environment.
+func archiveFlags(flags map[string]*flagState, body interface{}) (interface{}, error) {
+	fields, _ := body.(map[string]interface{})
+	keys, _ := fields["keys"].([]interface{})
+
+	for _, k := range keys {
+		key, _ := k.(string)
+		flag, ok := flags[key]
+		if !ok {
+			return nil, errorf(http.StatusBadRequest, "keys", "unknown flag %s", key)
+		}
+
+		for env, ruleset := range flag.rulesets {
+			if enabled, _ := ruleset.(map[string]interface{})["enabled"].(bool); enabled {
+				return nil, errorf(http.StatusConflict, "keys", "flag %s is enabled in environment %s, disable it before archiving", key, env)
+			}
+		}
+	}
+
+	items := []interface{}{}
+	for _, k := range keys {
+		flag := flags[k.(string)].flag
+		flag["archived"] = true
+		mergeFields(flag, nil)
+		items = append(items, flag)
+	}
+
+	return map[string]interface{}{"items": items}, nil
+}
+
+func listVariations(flag *flagState) map[string]interface{} {
+	keys := make([]string, 0, len(flag.variations))
+	for key := range flag.variations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	items := []interface{}{}
+	for _, key := range keys {
+		items = append(items, flag.variations[key])
+	}
+
+	return map[string]interface{}{"items": items}
+}
+
+func createVariation(flag *flagState, body interface{}) (interface{}, error) {
+	fields, _ := body.(map[string]interface{})
+
+	key, _ := fields["key"].(string)
+	if key == "" {
+		return nil, errorf(http.StatusBadRequest, "key", "key is required")
+	}
+
+	if _, exists := flag.variations[key]; exists {
+		return nil, errorf(http.StatusConflict, "variations", "variation %s already exists", key)
+	}
+
+	variation := map[string]interface{}{
+		"key":       key,
+		"archived":  false,
+		"variables": map[string]interface{}{},
+	}
+	mergeFields(variation, fields)
+	if err := checkVariables(flag, variation); err != nil {
+		return nil, err
+	}
+	flag.variations[key] = variation
+
+	return variation, nil
+}
+
+// checkVariables rejects a variation carrying values for variables the flag
+// doesn't define, like Optimizely does.
+func checkVariables(flag *flagState, variation map[string]interface{}) error {
+	definitions, _ := flag.flag["variable_definitions"].(map[string]interface{})
+	variables, _ := variation["variables"].(map[string]interface{})
+	for variable := range variables {
+		if _, ok := definitions[variable]; !ok {
+			return errorf(http.StatusBadRequest, "variations", "variable %s isn't defined by the flag", variable)
+		}
+	}
+
+	return nil
+}
+
+func decodePatch(body interface{}) ([]patchOp, error) {
+	raw, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var ops []patchOp
+	if err := json.Unmarshal(raw, &ops); err != nil {
+		return nil, errorf(http.StatusBadRequest, "", "expected a list of JSON patch operations: %s", err)
+	}
+
+	return ops, nil
+}
+
+// patchFlag applies a JSON patch to the name, description and variable
+// definitions of a flag, refusing like Optimizely to remove a variable that
+// variations still carry a value for.
+func patchFlag(flag *flagState, body interface{}) (interface{}, error) {
+	ops, err := decodePatch(body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range ops {
+		if op.Path != "/name" && op.Path != "/description" && !strings.HasPrefix(op.Path, "/variable_definitions/") {
+			return nil, errorf(http.StatusBadRequest, "", "%s can't be patched", op.Path)
+		}
+
+		tokens, _ := parsePointer(op.Path)
+		if op.Op != "remove" || len(tokens) != 2 {
+			continue
+		}
+
+		variable := tokens[1]
+		for key, variation := range flag.variations {
+			variables, _ := variation["variables"].(map[string]interface{})
+			if _, ok := variables[variable]; ok && variation["archived"] != true {
+				return nil, errorf(http.StatusBadRequest, "variable_definitions", "variable %s is used by variation %s", variable, key)
+			}
+		}
+	}
+
+	patched, err := applyPatch(flag.flag, ops)
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "variable_definitions", "%s", err)
+	}
+
+	flag.flag = patched.(map[string]interface{})
+	mergeFields(flag.flag, nil)
+
+	return flag.flag, nil
+}
+
+func patchVariation(flag *flagState, key string, body interface{}) (interface{}, error) {
+	variation, ok := flag.variations[key]
+	if !ok {
+		return nil, errNotFound
+	}
+
+	ops, err := decodePatch(body)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := applyPatch(variation, ops)
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "variations", "%s", err)
+	}
+
+	variation = patched.(map[string]interface{})
+	if err := checkVariables(flag, variation); err != nil {
+		return nil, err
+	}
+
+	mergeFields(variation, nil)
+	flag.variations[key] = variation
+
+	return variation, nil
+}
+
+// archiveVariations archives the variations listed in keys, refusing, like
+// Optimizely, the ones still served by a rule.
+func archiveVariations(flag *flagState, body interface{}) (interface{}, error) {
+	fields, _ := body.(map[string]interface{})
+	keys, _ := fields["keys"].([]interface{})
+
+	for _, k := range keys {
+		key, _ := k.(string)
+		if _, ok := flag.variations[key]; !ok {
+			return nil, errorf(http.StatusBadRequest, "keys", "unknown variation %s", key)
+		}
+
+		for env, ruleset := range flag.rulesets {
+			rules, _ := ruleset.(map[string]interface{})["rules"].(map[string]interface{})
+			for ruleKey, rule := range rules {
+				variations, _ := rule.(map[string]interface{})["variations"].(map[string]interface{})
+				if _, ok := variations[key]; ok {
+					return nil, errorf(http.StatusBadRequest, "keys", "variation %s is served by rule %s in environment %s", key, ruleKey, env)
+				}
+			}
+		}
+	}
+
+	for _, k := range keys {
+		variation := flag.variations[k.(string)]
+		variation["archived"] = true
+		mergeFields(variation, nil)
+	}
+
+	return listVariations(flag), nil
+}
+
+// patchRuleset applies a JSON patch to a ruleset and, like Optimizely,
+// rejects results where rule_priorities doesn't list every rule exactly once,
+// a rule's variations don't add up to 100% or it serves an unknown variation.
+func patchRuleset(flag *flagState, env string, body interface{}) (interface{}, error) {
+	ops, err := decodePatch(body)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := applyPatch(flag.rulesets[env], ops)
+	if err != nil {
+		return nil, errorf(http.StatusBadRequest, "rules", "%s", err)
+	}
+
+	ruleset, _ := patched.(map[string]interface{})
+	rules, _ := ruleset["rules"].(map[string]interface{})
+	priorities, _ := ruleset["rule_priorities"].([]interface{})
+
+	seen := make(map[string]bool)
+	for _, priority := range priorities {
+		key, _ := priority.(string)
+		if _, ok := rules[key]; !ok || seen[key] {
+			return nil, errorf(http.StatusBadRequest, "rule_priorities", "rule_priorities must list every rule exactly once, got %v", priorities)
+		}
+		seen[key] = true
+	}
+
+	if len(seen) != len(rules) {
+		return nil, errorf(http.StatusBadRequest, "rule_priorities", "rule_priorities must list every rule exactly once, got %v", priorities)
+	}
+
+	for ruleKey, rule := range rules {
+		ruleMap, _ := rule.(map[string]interface{})
+		variations, _ := ruleMap["variations"].(map[string]interface{})
+
+		total := 0.0
+		for _, variation := range variations {
+			percentage, _ := variation.(map[string]interface{})["percentage_included"].(float64)
+			total += percentage
+		}
+		if total != 10000 {
+			return nil, errorf(http.StatusBadRequest, "rules", "the variations of rule %s must add up to 10000, got %v", ruleKey, total)
+		}
+
+		for variationKey := range variations {
+			variation, ok := flag.variations[variationKey]
+			if !ok {
+				return nil, errorf(http.StatusBadRequest, "rules", "rule %s serves unknown variation %s", ruleKey, variationKey)
+			}
+			if archived, _ := variation["archived"].(bool); archived {
+				return nil, errorf(http.StatusBadRequest, "rules", "rule %s serves archived variation %s", ruleKey, variationKey)
+			}
+		}
+	}
+
+	flag.rulesets[env] = ruleset
+	return ruleset, nil
+}
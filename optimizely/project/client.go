@@ -0,0 +1,12 @@
+package project
+
+import "context"
+
+type ProjectClient interface {
+	GetProject(ctx context.Context, projectId int64) (Project, error)
+	ListProjects(ctx context.Context) ([]Project, error)
+	ListSDKKeys(ctx context.Context, projectId int64) (map[string]string, error)
+	CreateProject(ctx context.Context, proj Project) (Project, error)
+	UpdateProject(ctx context.Context, proj Project) (Project, error)
+	ArchiveProject(ctx context.Context, projectId int64) (Project, error)
+}
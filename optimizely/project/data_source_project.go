@@ -2,28 +2,170 @@ package project
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
 
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/apierror"
 )
 
+type Project struct {
+	ID             int64  `json:"id,omitempty"`
+	AccountId      int64  `json:"account_id,omitempty"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	Platform       string `json:"platform,omitempty"`
+	Status         string `json:"status,omitempty"`
+	IsFlagsEnabled bool   `json:"is_flags_enabled,omitempty"`
+	Created        string `json:"created,omitempty"`
+	LastModified   string `json:"last_modified,omitempty"`
+}
+
 func DataSourceProject() *schema.Resource {
 	return &schema.Resource{
-		ReadContext: dataSourceIngredientsRead,
+		ReadContext: dataSourceProjectRead,
 		Schema: map[string]*schema.Schema{
 			"id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Computed:     true,
+				ExactlyOneOf: []string{"id", "name"},
+				Description:  "The ID of the Project",
+			},
+			"name": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Computed:    true,
+				Description: "The name of the Project, looked up when id isn't set",
+			},
+			"description": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"account_id": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"platform": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "web for Web Experimentation projects, custom otherwise",
+			},
+			"status": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"is_feature_experimentation": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the Project uses flags, as Feature Experimentation projects do",
+			},
+			"sdk_keys": {
+				Type:        schema.TypeMap,
+				Computed:    true,
+				Description: "The SDK key of each environment of the Project, by environment key",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"created": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"last_modified": {
 				Type:     schema.TypeString,
-				Required: true,
+				Computed: true,
 			},
 		},
 	}
 }
 
-func dataSourceIngredientsRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	var diags diag.Diagnostics
+func dataSourceProjectRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(ProjectClient)
 
-	// fmt.Printf("----- %+v \n\n", d.Get("id"))
-	d.SetId(d.Get("id").(string))
+	var proj Project
+	if id, ok := d.GetOk("id"); ok {
+		projectId, err := strconv.ParseInt(id.(string), 10, 64)
+		if err != nil {
+			return diag.Diagnostics{{
+				Severity:      diag.Error,
+				Summary:       "Failed to parse Project ID",
+				Detail:        fmt.Sprintf("%s: %+v", id, err),
+				AttributePath: cty.GetAttrPath("id"),
+			}}
+		}
+
+		proj, err = client.GetProject(ctx, projectId)
+		if err != nil {
+			return apierror.Diagnostics("Failed to read Project from Optimizely", err, nil)
+		}
+	} else {
+		var diags diag.Diagnostics
+		proj, diags = findProjectByName(ctx, client, d.Get("name").(string))
+		if diags.HasError() {
+			return diags
+		}
+	}
+
+	sdkKeys := map[string]string{}
+	if proj.IsFlagsEnabled {
+		var err error
+		sdkKeys, err = client.ListSDKKeys(ctx, proj.ID)
+		if err != nil {
+			return apierror.Diagnostics("Failed to list Project SDK keys from Optimizely", err, nil)
+		}
+	}
+
+	d.SetId(strconv.FormatInt(proj.ID, 10))
+	d.Set("name", proj.Name)
+	d.Set("description", proj.Description)
+	d.Set("account_id", proj.AccountId)
+	d.Set("platform", proj.Platform)
+	d.Set("status", proj.Status)
+	d.Set("is_feature_experimentation", proj.IsFlagsEnabled)
+	d.Set("sdk_keys", sdkKeys)
+	d.Set("created", proj.Created)
+	d.Set("last_modified", proj.LastModified)
+
+	return nil
+}
+
+// findProjectByName returns the only project named name, names aren't unique
+// in Optimizely so several matches are an error.
+func findProjectByName(ctx context.Context, client ProjectClient, name string) (Project, diag.Diagnostics) {
+	projects, err := client.ListProjects(ctx)
+	if err != nil {
+		return Project{}, apierror.Diagnostics("Failed to list Projects from Optimizely", err, nil)
+	}
+
+	var matches []Project
+	var ids []string
+	for _, proj := range projects {
+		if proj.Name == name {
+			matches = append(matches, proj)
+			ids = append(ids, strconv.FormatInt(proj.ID, 10))
+		}
+	}
+
+	switch len(matches) {
+	case 1:
+		return matches[0], nil
+	case 0:
+		return Project{}, diag.Diagnostics{{
+			Severity:      diag.Error,
+			Summary:       "Project not found in Optimizely",
+			Detail:        fmt.Sprintf("no project is named %q", name),
+			AttributePath: cty.GetAttrPath("name"),
+		}}
+	}
 
-	return diags
+	return Project{}, diag.Diagnostics{{
+		Severity:      diag.Error,
+		Summary:       "Several Projects match in Optimizely",
+		Detail:        fmt.Sprintf("projects %s are all named %q, set id instead", strings.Join(ids, ", "), name),
+		AttributePath: cty.GetAttrPath("name"),
+	}}
 }
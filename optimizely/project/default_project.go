@@ -0,0 +1,51 @@
+package project
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// DefaultProjectClient is implemented by clients that carry the provider
+// level project_id.
+type DefaultProjectClient interface {
+	DefaultProjectId() int
+}
+
+// SetDefaultProject is a CustomizeDiffFunc for resources with a "project"
+// attribute: when the resource leaves it unset, the provider's project_id is
+// planned in its place. The config tells whether it's unset, as a new
+// resource plans an unset computed attribute as unknown rather than zero.
+func SetDefaultProject(ctx context.Context, d *schema.ResourceDiff, m interface{}) error {
+	config := d.GetRawConfig()
+	if config.IsNull() || !config.GetAttr("project").IsNull() {
+		return nil
+	}
+
+	projectId := 0
+	if client, ok := m.(DefaultProjectClient); ok {
+		projectId = client.DefaultProjectId()
+	}
+
+	if projectId == 0 {
+		return fmt.Errorf("project is not set: set it on the resource or set project_id on the provider")
+	}
+
+	return d.SetNew("project", projectId)
+}
+
+// ResolveProjectId returns the data source's project, falling back to the
+// provider's project_id. Data sources have no CustomizeDiff to plan the
+// default with SetDefaultProject, so they resolve it when read.
+func ResolveProjectId(d *schema.ResourceData, m interface{}) int {
+	if projectId := d.Get("project").(int); projectId != 0 {
+		return projectId
+	}
+
+	if client, ok := m.(DefaultProjectClient); ok {
+		return client.DefaultProjectId()
+	}
+
+	return 0
+}
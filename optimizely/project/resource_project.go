@@ -0,0 +1,199 @@
+package project
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/apierror"
+)
+
+const (
+	PlatformWeb    = "web"
+	PlatformCustom = "custom"
+
+	StatusActive   = "active"
+	StatusArchived = "archived"
+)
+
+var projectFields = map[string]string{
+	"name":        "name",
+	"description": "description",
+	"platform":    "platform",
+	"status":      "status",
+}
+
+func ResourceProject() *schema.Resource {
+	return &schema.Resource{
+		Schema: map[string]*schema.Schema{
+			"id": {
+				Type:        schema.TypeString,
+				Computed:    true,
+				Description: "The ID of the Project",
+			},
+			"name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringIsNotWhiteSpace,
+				Description:  "The name of the Project",
+			},
+			"description": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "A short description of the Project",
+			},
+			"platform": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      PlatformCustom,
+				ValidateFunc: validation.StringInSlice([]string{PlatformWeb, PlatformCustom}, false),
+				Description:  "web for Web Experimentation projects, custom for Feature Experimentation ones",
+			},
+			"status": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      StatusActive,
+				ValidateFunc: validation.StringInSlice([]string{StatusActive, StatusArchived}, false),
+				Description:  "Whether the Project is active or archived",
+			},
+			"account_id": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"is_feature_experimentation": {
+				Type:        schema.TypeBool,
+				Computed:    true,
+				Description: "Whether the Project uses flags, as Feature Experimentation projects do",
+			},
+			"created": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"last_modified": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(10 * time.Minute),
+			Update: schema.DefaultTimeout(10 * time.Minute),
+			Delete: schema.DefaultTimeout(10 * time.Minute),
+		},
+		CreateContext: resourceProjectCreate,
+		ReadContext:   resourceProjectRead,
+		UpdateContext: resourceProjectUpdate,
+		DeleteContext: resourceProjectDelete,
+		Importer: &schema.ResourceImporter{
+			StateContext: resourceProjectImport,
+		},
+	}
+}
+
+func resourceProjectCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(ProjectClient)
+
+	proj := Project{
+		Name:        d.Get("name").(string),
+		Description: d.Get("description").(string),
+		Platform:    d.Get("platform").(string),
+		Status:      d.Get("status").(string),
+	}
+
+	projResp, err := client.CreateProject(ctx, proj)
+	if err != nil {
+		return apierror.Diagnostics("Failed to create Project in Optimizely", err, projectFields)
+	}
+
+	d.SetId(strconv.FormatInt(projResp.ID, 10))
+	return resourceProjectRead(ctx, d, m)
+}
+
+func resourceProjectRead(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	client := m.(ProjectClient)
+
+	projectId, err := strconv.ParseInt(d.Id(), 10, 64)
+	if err != nil {
+		return diag.Errorf("failed to parse Project ID %q: %s", d.Id(), err)
+	}
+
+	proj, err := client.GetProject(ctx, projectId)
+	if apierror.IsNotFound(err) {
+		tflog.Warn(ctx, "Project not found in Optimizely, removing it from state", map[string]interface{}{
+			"id": d.Id(),
+		})
+
+		d.SetId("")
+		return diags
+	}
+	if err != nil {
+		return apierror.Diagnostics("Failed to read Project from Optimizely", err, projectFields)
+	}
+
+	d.Set("name", proj.Name)
+	d.Set("description", proj.Description)
+	d.Set("platform", proj.Platform)
+	d.Set("status", proj.Status)
+	d.Set("account_id", proj.AccountId)
+	d.Set("is_feature_experimentation", proj.IsFlagsEnabled)
+	d.Set("created", proj.Created)
+	d.Set("last_modified", proj.LastModified)
+
+	return diags
+}
+
+func resourceProjectUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(ProjectClient)
+
+	projectId, err := strconv.ParseInt(d.Id(), 10, 64)
+	if err != nil {
+		return diag.Errorf("failed to parse Project ID %q: %s", d.Id(), err)
+	}
+
+	proj := Project{
+		ID:          projectId,
+		Name:        d.Get("name").(string),
+		Description: d.Get("description").(string),
+		Status:      d.Get("status").(string),
+	}
+
+	_, err = client.UpdateProject(ctx, proj)
+	if err != nil {
+		return apierror.Diagnostics("Failed to update Project in Optimizely", err, projectFields)
+	}
+
+	return resourceProjectRead(ctx, d, m)
+}
+
+func resourceProjectImport(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	if _, err := strconv.ParseInt(d.Id(), 10, 64); err != nil {
+		return nil, fmt.Errorf("expected the numeric ID of a Project, got %q", d.Id())
+	}
+
+	return []*schema.ResourceData{d}, nil
+}
+
+// resourceProjectDelete archives the project, Optimizely has no way to delete
+// one.
+func resourceProjectDelete(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	client := m.(ProjectClient)
+
+	projectId, err := strconv.ParseInt(d.Id(), 10, 64)
+	if err != nil {
+		return diag.Errorf("failed to parse Project ID %q: %s", d.Id(), err)
+	}
+
+	_, err = client.ArchiveProject(ctx, projectId)
+	if err != nil {
+		return apierror.Diagnostics("Failed to archive Project in Optimizely", err, projectFields)
+	}
+
+	return nil
+}
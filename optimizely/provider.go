@@ -2,9 +2,12 @@ package optimizely
 
 import (
 	"context"
+	"os"
+	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 	"github.com/pffreitas/optimizely-terraform-provider/optimizely/audience"
 	"github.com/pffreitas/optimizely-terraform-provider/optimizely/client"
 	"github.com/pffreitas/optimizely-terraform-provider/optimizely/environment"
@@ -13,38 +16,155 @@ import (
 )
 
 func Provider() *schema.Provider {
-	return &schema.Provider{
+	return New("dev")
+}
+
+// New returns the provider, version is reported in the User-Agent of every
+// API request.
+func New(version string) *schema.Provider {
+	p := &schema.Provider{
 		Schema: map[string]*schema.Schema{
 			"host": {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:        schema.TypeString,
+				Optional:    true,
+				Default:     DefaultHost,
+				Description: "Optimizely API address",
 			},
+			"endpoints": endpointsSchema(),
 			"token": {
-				Type:     schema.TypeString,
-				Required: true,
+				Type:          schema.TypeString,
+				Optional:      true,
+				Sensitive:     true,
+				ConflictsWith: []string{"token_file", "token_command"},
+				Description:   "Optimizely personal access token, defaults to the " + TokenEnvVar + " environment variable",
+			},
+			"token_file": {
+				Type:          schema.TypeString,
+				Optional:      true,
+				ConflictsWith: []string{"token", "token_command"},
+				Description:   "Path to a file holding the Optimizely personal access token",
+			},
+			"token_command": {
+				Type:          schema.TypeList,
+				Optional:      true,
+				MinItems:      1,
+				ConflictsWith: []string{"token", "token_file"},
+				Description:   "Command, and its arguments, printing the Optimizely personal access token to stdout",
+				Elem: &schema.Schema{
+					Type: schema.TypeString,
+				},
+			},
+			"project_id": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Default project for resources that don't set their own project",
+			},
+			"max_retries": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      client.DefaultMaxRetries,
+				ValidateFunc: validation.IntAtLeast(0),
+				Description:  "Maximum number of times a rate limited (429) or failed (5xx) request is retried",
+			},
+			"retry_max_wait": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      int(client.DefaultRetryMaxWait / time.Second),
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Maximum number of seconds to wait between retries, including waits requested by Retry-After",
+			},
+			"request_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      int(client.DefaultRequestTimeout / time.Second),
+				ValidateFunc: validation.IntAtLeast(1),
+				Description:  "Number of seconds a single API request may take, retries get a fresh timeout",
+			},
+			"proxy_url": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.IsURLWithScheme([]string{"http", "https", "socks5"}),
+				Description:  "Proxy for all API requests, defaults to the HTTPS_PROXY and NO_PROXY environment variables",
+			},
+			"ca_cert_file": {
+				Type:        schema.TypeString,
+				Optional:    true,
+				Description: "Path to a PEM bundle of certificate authorities trusted in addition to the system ones",
+			},
+			"insecure_skip_verify": {
+				Type:        schema.TypeBool,
+				Optional:    true,
+				Default:     false,
+				Description: "Skip TLS certificate verification, only meant for local stand-ins of the API",
 			},
 		},
 		ResourcesMap: map[string]*schema.Resource{
 			"optimizely_feature":  flag.ResourceFeature(),
 			"optimizely_audience": audience.ResourceAudience(),
+			"optimizely_project":  project.ResourceProject(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
 			"optimizely_environment": environment.DataSourceEnvironment(),
 			"optimizely_project":     project.DataSourceProject(),
 		},
-		ConfigureContextFunc: providerConfigure,
 	}
+
+	p.ConfigureContextFunc = func(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+		return providerConfigure(ctx, d, p.UserAgent("terraform-provider-optimizely", version))
+	}
+
+	return p
 }
 
-func providerConfigure(ctx context.Context, d *schema.ResourceData) (interface{}, diag.Diagnostics) {
+func providerConfigure(ctx context.Context, d *schema.ResourceData, userAgent string) (interface{}, diag.Diagnostics) {
 	var diags diag.Diagnostics
 
-	address := d.Get("host").(string)
-	token := d.Get("token").(string)
+	restAPI, flagsAPI, diags := resolveEndpoints(d)
+	if diags.HasError() {
+		return nil, diags
+	}
+
+	token, tokenDiags := resolveToken(ctx, d)
+	if tokenDiags.HasError() {
+		return nil, append(diags, tokenDiags...)
+	}
+
+	// only a recording sees the real token, the one replays run with is a
+	// placeholder that must not be scrubbed from the cassette
+	var secrets []string
+	if os.Getenv(client.RecordModeEnvVar) == client.RecordMode {
+		secrets = []string{token}
+	}
+
+	httpClient, err := client.NewHTTPClient(client.TransportConfig{
+		Timeout:            time.Duration(d.Get("request_timeout").(int)) * time.Second,
+		ProxyURL:           d.Get("proxy_url").(string),
+		CACertFile:         d.Get("ca_cert_file").(string),
+		InsecureSkipVerify: d.Get("insecure_skip_verify").(bool),
+		RecordMode:         os.Getenv(client.RecordModeEnvVar),
+		Cassette:           os.Getenv(client.CassetteEnvVar),
+		Secrets:            secrets,
+	})
+	if err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Failed to configure the Optimizely HTTP client",
+			Detail:   err.Error(),
+		})
+
+		return nil, diags
+	}
 
 	optimizelyClient := client.OptimizelyClient{
-		Address: address,
-		Token:   token,
+		RestAPI:      restAPI,
+		FlagsAPI:     flagsAPI,
+		Token:        token,
+		UserAgent:    userAgent,
+		HTTPClient:   httpClient,
+		ProjectId:    d.Get("project_id").(int),
+		MaxRetries:   d.Get("max_retries").(int),
+		RetryMaxWait: time.Duration(d.Get("retry_max_wait").(int)) * time.Second,
 	}
 
 	return optimizelyClient, diags
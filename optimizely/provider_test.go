@@ -2,8 +2,13 @@ package optimizely
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
 	"text/template"
@@ -12,20 +17,102 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/apierror"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/client"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/flag"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/internal/fakeapi"
+	"github.com/pffreitas/optimizely-terraform-provider/optimizely/project"
 )
 
-var testAccProviders map[string]*schema.Provider
+var testAccProviderFactories map[string]func() (*schema.Provider, error)
 var testAccProvider *schema.Provider
 
 type TestConfig struct {
+	Provider     string
 	AudienceName string
 	FlagKey      string
 }
 
+// testAccProjectId is the project the acceptance tests run in, it has a sit,
+// uat and prod environment.
+const testAccProjectId = 20410805626
+
+// testAccProviderConfig returns the provider block for acceptance tests. By
+// default it points the provider at an in-memory fake of the Optimizely API;
+// with OPTIMIZELY_ACC_LIVE=1 the tests run against Optimizely itself,
+// authenticated by OPTIMIZELY_API_TOKEN.
+//
+// OPTIMIZELY_RECORD=record runs against Optimizely too, recording every
+// interaction into testdata/cassettes, and OPTIMIZELY_RECORD=replay replays
+// them without credentials. Both seed gofakeit so the generated names match
+// the recording; tests must call this before generating any.
+func testAccProviderConfig(t *testing.T) string {
+	switch os.Getenv(client.RecordModeEnvVar) {
+	case client.RecordMode:
+		cassette := testAccCassette(t)
+		os.Remove(cassette)
+		t.Setenv(client.CassetteEnvVar, cassette)
+		t.Cleanup(func() { client.CloseCassette(cassette) })
+		gofakeit.Seed(1)
+
+		return testAccLiveProviderConfig()
+
+	case client.ReplayMode:
+		cassette := testAccCassette(t)
+		if _, err := os.Stat(cassette); err != nil {
+			t.Fatalf("no cassette recorded for %s, record it with make testacc-record: %s", t.Name(), err)
+		}
+		t.Setenv(client.CassetteEnvVar, cassette)
+		t.Cleanup(func() { client.CloseCassette(cassette) })
+		gofakeit.Seed(1)
+
+		return fmt.Sprintf(`
+provider "optimizely" {
+	token      = "replay"
+	project_id = %d
+}
+`, testAccProjectId)
+	}
+
+	if os.Getenv("OPTIMIZELY_ACC_LIVE") != "" {
+		return testAccLiveProviderConfig()
+	}
+
+	server := fakeapi.NewServer("dev", "sit", "uat", "prod")
+	server.AddProject(testAccProjectId, "Terraform acceptance tests")
+	t.Cleanup(server.Close)
+
+	return fmt.Sprintf(`
+provider "optimizely" {
+	token      = %q
+	project_id = %d
+
+	endpoints {
+		rest_api  = %q
+		flags_api = %q
+	}
+}
+`, server.Token, testAccProjectId, server.RestAPI(), server.FlagsAPI())
+}
+
+func testAccLiveProviderConfig() string {
+	return fmt.Sprintf(`
+provider "optimizely" {
+	project_id = %d
+}
+`, testAccProjectId)
+}
+
+func testAccCassette(t *testing.T) string {
+	return filepath.Join("testdata", "cassettes", t.Name()+".json")
+}
+
 func init() {
 	testAccProvider = Provider()
-	testAccProviders = map[string]*schema.Provider{
-		"optimizely": testAccProvider,
+	testAccProviderFactories = map[string]func() (*schema.Provider, error){
+		"optimizely": func() (*schema.Provider, error) {
+			return testAccProvider, nil
+		},
 	}
 }
 
@@ -39,32 +126,28 @@ func TestProvider_impl(t *testing.T) {
 	var _ *schema.Provider = Provider()
 }
 
-func testAccCheckHashicupsOrderConfigBasic() string {
-	return `
-	provider "optimizely" {
-		host  = "https://api.optimizely.com/v2"
+func testAccCheckHashicupsOrderConfigBasic(provider string) string {
+	return provider + `
+	data "optimizely_environment" "sit" {
+		key = "sit"
 	}
 	
-	data "optimizely_environment" "sit" {}
-	
 	resource "optimizely_audience" "country_ec" {
 		name = "COUNTRY_EC_TERRAFORM"
-		conditions = "[\"and\", {\"type\": \"custom_attribute\", \"name\": \"COUNTRY\", \"value\": \"ec\"}]"
+		conditions = jsonencode(["and", {"type": "custom_attribute", "name": "COUNTRY", "value": "ec"}])
 	}
 	`
 }
 
-func testAccCheckHashicupsOrderConfigBasic2() string {
-	return `
-	provider "optimizely" {
-		host  = "https://api.optimizely.com/v2"
+func testAccCheckHashicupsOrderConfigBasic2(provider string) string {
+	return provider + `
+	data "optimizely_environment" "sit" {
+		key = "sit"
 	}
 	
-	data "optimizely_environment" "sit" {}
-	
 	resource "optimizely_audience" "country_ec" {
 		name = "COUNTRY_EC_TERRAFORM_2"
-		conditions = "[\"and\", {\"type\": \"custom_attribute\", \"name\": \"COUNTRY\", \"value\": \"ec\"}]"
+		conditions = jsonencode(["and", {"type": "custom_attribute", "name": "COUNTRY", "value": "ec"}])
 	}
 	`
 }
@@ -81,50 +164,365 @@ func testAccCheckHashicupsOrderExists(n string) resource.TestCheckFunc {
 			return fmt.Errorf("No OrderID set")
 		}
 
-		attr, _ := json.Marshal(rs.Primary.Attributes)
-		fmt.Printf("---- %+v \n", string(attr))
+		return nil
+	}
+}
+
+// testAccCheckResourceId stores the ID of resource n into id, for steps that
+// change the resource outside of Terraform.
+func testAccCheckResourceId(n string, id *string) resource.TestCheckFunc {
+	return func(s *terraform.State) error {
+		rs, ok := s.RootModule().Resources[n]
+		if !ok {
+			return fmt.Errorf("Not found: %s", n)
+		}
 
+		*id = rs.Primary.ID
 		return nil
 	}
 }
 
 func TestAccHashicupsOrderBasic(t *testing.T) {
+	provider := testAccProviderConfig(t)
+
+	var audienceId string
 
 	resource.Test(t, resource.TestCase{
-		Providers: testAccProviders,
+		ProviderFactories: testAccProviderFactories,
 		Steps: []resource.TestStep{
 			{
-				Config: testAccCheckHashicupsOrderConfigBasic(),
+				Config: testAccCheckHashicupsOrderConfigBasic(provider),
 				Check: resource.ComposeTestCheckFunc(
 					testAccCheckHashicupsOrderExists("optimizely_audience.country_ec"),
 				),
 			},
 			{
-				Config: testAccCheckHashicupsOrderConfigBasic2(),
+				Config: testAccCheckHashicupsOrderConfigBasic2(provider),
 				Check: resource.ComposeTestCheckFunc(
 					testAccCheckHashicupsOrderExists("optimizely_audience.country_ec"),
+					testAccCheckResourceId("optimizely_audience.country_ec", &audienceId),
 				),
 			},
+			{
+				ResourceName:      "optimizely_audience.country_ec",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				// an audience archived in the UI is planned for recreation
+				PreConfig: func() {
+					c := testAccProvider.Meta().(client.OptimizelyClient)
+					if _, err := c.ArchiveAudience(context.Background(), audienceId); err != nil {
+						t.Fatalf("failed to archive audience %s: %s", audienceId, err)
+					}
+				},
+				Config:             testAccCheckHashicupsOrderConfigBasic2(provider),
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
 		},
 	})
 }
 
-var hclCommon = `
-variable "api_host" {
-	type = string
-	default = "https://api.optimizely.com"
+func TestAccAudienceConditionGroup(t *testing.T) {
+	provider := testAccProviderConfig(t)
+
+	config := func(conditions string) string {
+		return provider + `
+		resource "optimizely_audience" "adults_us" {
+			name = "ADULTS_US_TERRAFORM"
+
+			condition_group {
+				` + conditions + `
+			}
+		}
+		`
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: config(`
+				condition {
+					name       = "AGE"
+					match_type = "gt"
+					value      = "adult"
+				}
+				`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`condition AGE: value "adult" isn't a number`),
+			},
+			{
+				Config: config(`
+				condition {
+					name       = "COUNTRY"
+					match_type = "exact"
+				}
+				`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`condition COUNTRY: exact conditions need a value`),
+			},
+			{
+				Config: config(`
+				condition {
+					name       = "COUNTRY"
+					match_type = "equals"
+					value      = "us"
+				}
+				`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`expected condition_group.0.condition.0.match_type to be one of`),
+			},
+			{
+				// the value is only known once the other audience is created
+				Config: config(`
+				condition_group {
+					condition {
+						name  = "SEGMENT"
+						value = optimizely_audience.segment.id
+					}
+				}
+				`) + `
+				resource "optimizely_audience" "segment" {
+					name       = "SEGMENT_TERRAFORM"
+					conditions = jsonencode(["and"])
+				}
+				`,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				Config: config(`
+				condition_group {
+					operator = "or"
+
+					condition {
+						name  = "COUNTRY"
+						value = "us"
+					}
+
+					condition {
+						name       = "AGE"
+						match_type = "gt"
+						value      = "18"
+					}
+				}
+
+				condition_group {
+					operator = "not"
+
+					condition {
+						name       = "EMPLOYEE"
+						match_type = "exists"
+					}
+				}
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("optimizely_audience.adults_us", "conditions", `["and",["or",{"match_type":"exact","name":"COUNTRY","type":"custom_attribute","value":"us"},{"match_type":"gt","name":"AGE","type":"custom_attribute","value":18}],["not",{"match_type":"exists","name":"EMPLOYEE","type":"custom_attribute"}]]`),
+					resource.TestCheckResourceAttr("optimizely_audience.adults_us", "condition_group.0.condition_group.0.condition.1.value", "18"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccAudienceConditionsJSON(t *testing.T) {
+	provider := testAccProviderConfig(t)
+
+	config := func(conditions string) string {
+		return provider + `
+		resource "optimizely_audience" "adults" {
+			name       = "ADULTS_TERRAFORM"
+			conditions = <<-EOT
+				` + conditions + `
+			EOT
+		}
+		`
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      config(`["and", {"type": "custom_attribute", "name": "AGE"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`expected conditions to be a JSON array of conditions`),
+			},
+			{
+				Config: config(`["and", {"value": 18.0, "type": "custom_attribute", "name": "AGE", "match_type": "gt"}]`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("optimizely_audience.adults", "conditions", `["and",{"match_type":"gt","name":"AGE","type":"custom_attribute","value":18}]`),
+					resource.TestCheckResourceAttr("optimizely_audience.adults", "project", fmt.Sprint(testAccProjectId)),
+				),
+			},
+			{
+				// the same conditions written differently plan no change
+				Config:   config(`["and",{"match_type":"gt","name":"AGE","type":"custom_attribute","value":1.8e1}]`),
+				PlanOnly: true,
+			},
+			{
+				// numbers past float64 precision keep their digits
+				Config: config(`["and", {"value": 9007199254740993.50, "type": "custom_attribute", "name": "AGE", "match_type": "gt"}]`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("optimizely_audience.adults", "conditions", `["and",{"match_type":"gt","name":"AGE","type":"custom_attribute","value":9007199254740993.5}]`),
+				),
+			},
+		},
+	})
 }
 
-variable "api_token" {
-	type = string
-	sensitive = true
+func TestAccEnvironmentDataSource(t *testing.T) {
+	provider := testAccProviderConfig(t)
+
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: provider + `
+				data "optimizely_environment" "prod" {
+					key = "prdo"
+				}
+				`,
+				ExpectError: regexp.MustCompile(`project \d+ has no environment "prdo"`),
+			},
+			{
+				Config: provider + `
+				data "optimizely_environment" "prod" {
+					key = "prod"
+				}
+				`,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("data.optimizely_environment.prod", "id", "prod"),
+					resource.TestCheckResourceAttr("data.optimizely_environment.prod", "project", fmt.Sprint(testAccProjectId)),
+					resource.TestCheckResourceAttr("data.optimizely_environment.prod", "is_primary", "true"),
+					resource.TestCheckResourceAttr("data.optimizely_environment.prod", "archived", "false"),
+					resource.TestCheckResourceAttrSet("data.optimizely_environment.prod", "environment_id"),
+					resource.TestCheckResourceAttrSet("data.optimizely_environment.prod", "name"),
+					resource.TestCheckResourceAttrSet("data.optimizely_environment.prod", "priority"),
+					resource.TestCheckResourceAttrSet("data.optimizely_environment.prod", "sdk_key"),
+					resource.TestMatchResourceAttr("data.optimizely_environment.prod", "datafile_url", regexp.MustCompile(`^https://.+\.json$`)),
+				),
+			},
+		},
+	})
 }
 
-provider "optimizely" {
-	host  = var.api_host
-	token = var.api_token
+func TestAccProjectDataSource(t *testing.T) {
+	provider := testAccProviderConfig(t)
+
+	config := provider + fmt.Sprintf(`
+	data "optimizely_project" "by_id" {
+		id = %d
+	}
+
+	data "optimizely_project" "by_name" {
+		name = data.optimizely_project.by_id.name
+	}
+	`, testAccProjectId)
+
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config: provider + `
+				data "optimizely_project" "missing" {
+					name = "no project is named like this"
+				}
+				`,
+				ExpectError: regexp.MustCompile(`no project is named "no project is named like this"`),
+			},
+			{
+				Config: config,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrSet("data.optimizely_project.by_id", "name"),
+					resource.TestCheckResourceAttrSet("data.optimizely_project.by_id", "account_id"),
+					resource.TestCheckResourceAttrSet("data.optimizely_project.by_id", "created"),
+					resource.TestCheckResourceAttrSet("data.optimizely_project.by_id", "last_modified"),
+					resource.TestCheckResourceAttr("data.optimizely_project.by_id", "platform", "custom"),
+					resource.TestCheckResourceAttr("data.optimizely_project.by_id", "status", "active"),
+					resource.TestCheckResourceAttr("data.optimizely_project.by_id", "is_feature_experimentation", "true"),
+					resource.TestCheckResourceAttrSet("data.optimizely_project.by_id", "sdk_keys.prod"),
+					resource.TestCheckResourceAttr("data.optimizely_project.by_name", "id", fmt.Sprint(testAccProjectId)),
+				),
+			},
+		},
+	})
+}
+
+func TestAccProjectResource(t *testing.T) {
+	provider := testAccProviderConfig(t)
+	name := "Terraform " + gofakeit.BS()
+
+	config := func(name, description string) string {
+		return provider + fmt.Sprintf(`
+		resource "optimizely_project" "product_line" {
+			name        = %q
+			description = %q
+		}
+
+		resource "optimizely_audience" "country_us" {
+			project    = optimizely_project.product_line.id
+			name       = "COUNTRY_US_TERRAFORM"
+			conditions = jsonencode(["and", {"type": "custom_attribute", "name": "COUNTRY", "value": "us"}])
+		}
+		`, name, description)
+	}
+
+	var projectId string
+
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: func(s *terraform.State) error {
+			c := testAccProvider.Meta().(client.OptimizelyClient)
+
+			id, err := strconv.ParseInt(projectId, 10, 64)
+			if err != nil {
+				return err
+			}
+
+			proj, err := c.GetProject(context.Background(), id)
+			if err != nil {
+				return err
+			}
+
+			if proj.Status != project.StatusArchived {
+				return fmt.Errorf("project %s is %s, expected it archived", projectId, proj.Status)
+			}
+
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: config(name, "Created by Terraform"),
+				Check: resource.ComposeTestCheckFunc(
+					testAccCheckResourceId("optimizely_project.product_line", &projectId),
+					resource.TestCheckResourceAttr("optimizely_project.product_line", "platform", "custom"),
+					resource.TestCheckResourceAttr("optimizely_project.product_line", "status", "active"),
+					resource.TestCheckResourceAttr("optimizely_project.product_line", "is_feature_experimentation", "true"),
+					resource.TestCheckResourceAttrPair("optimizely_audience.country_us", "project", "optimizely_project.product_line", "id"),
+				),
+			},
+			{
+				Config: config(name+" - Updated", "Updated by Terraform"),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPtr("optimizely_project.product_line", "id", &projectId),
+					resource.TestCheckResourceAttr("optimizely_project.product_line", "name", name+" - Updated"),
+					resource.TestCheckResourceAttr("optimizely_project.product_line", "description", "Updated by Terraform"),
+				),
+			},
+			{
+				ResourceName:      "optimizely_project.product_line",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+		},
+	})
 }
 
+var hclCommon = `
+{{.Provider}}
+
 data "optimizely_environment" "sit" {
 	key = "sit"
 }
@@ -227,6 +625,141 @@ func testFlagConfigBasic(testConfig TestConfig) (string, error) {
 	return buf.String(), err
 }
 
+func testFlagConfigInPlaceUpdate(testConfig TestConfig) (string, error) {
+	tmpl, err := template.New("").Parse(hclCommon + hclAudiences + `
+	resource "optimizely_feature" "dynamic_forms_terraform" {
+		project	= data.optimizely_project.bees_test_cac.id
+		name        = "{{.FlagKey}} - Terraform - Updated"
+		description = "{{.FlagKey}} - Terraform - Updated"
+		key         = "{{.FlagKey}}"
+	  
+		variable_schema {
+		  variable {
+			key         = "buttonPosition"
+			type         = "string"
+			default_value = "left"
+		  }
+
+		  variable {
+			key         = "buttonColor"
+			type         = "string"
+			default_value = "white"
+		  }
+
+		  variable {
+			key         = "buttonSize"
+			type         = "string"
+			default_value = "medium"
+		  }
+		}
+
+		variations { 
+			variation { 
+				key = "blackButtonOnTheRight"
+				name = "blackButtonOnTheRight"
+				description = "Black button on the right"
+				variables = {
+					buttonPosition = "right"
+					buttonColor = "black"
+					buttonSize = "large"
+				}
+			}
+
+			variation { 
+				key = "blackButtonOnTheLeft"
+				name = "blackButtonOnTheLeft"
+				description = "Black button on the left"
+				variables = {
+					buttonPosition = "left"
+					buttonColor = "black"
+				}
+			}
+		}
+	  
+		environment {
+			key     = "prod"
+			enabled = false
+		}
+
+		rules {
+		  rule {
+			key 		 = "us"
+			environments = [data.optimizely_environment.sit.id]
+			audience     = [optimizely_audience.country_us.id]
+			percentage_included = 50
+			deliver = "blackButtonOnTheRight"
+		  }
+		  
+		  rule {
+			key 		 = "br"
+			environments = [data.optimizely_environment.sit.id]
+			audience     = [optimizely_audience.country_br.id]
+			percentage_included = 75
+			deliver = "blackButtonOnTheLeft"
+		  }
+
+		  rule {
+			key 		 = "button-test"
+			type         = "a/b"
+			environments = [data.optimizely_environment.uat.id]
+			audience     = [optimizely_audience.country_us.id]
+			percentage_included = 20
+			distribution_mode = "stats_accel"
+
+			variation {
+				key    = "blackButtonOnTheRight"
+				weight = 60
+			}
+
+			variation {
+				key    = "blackButtonOnTheLeft"
+				weight = 40
+			}
+
+			metric {
+				event_id = 20410805700
+			}
+
+			metric {
+				event_id          = 20410805701
+				aggregator        = "sum"
+				winning_direction = "decreasing"
+			}
+		  }
+
+		  rule {
+			key 		 = "us-prod"
+			environments = [data.optimizely_environment.prod.id]
+
+			audience_conditions {
+				group {
+					operator  = "or"
+					audiences = [optimizely_audience.country_us.id, optimizely_audience.country_br.id]
+				}
+
+				group {
+					operator  = "not"
+					audiences = [optimizely_audience.country_br.id]
+				}
+			}
+
+			percentage_included = 10
+			deliver = "blackButtonOnTheRight"
+		  }
+		}
+	  }
+	`)
+
+	if err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	err = tmpl.Execute(buf, testConfig)
+
+	return buf.String(), err
+}
+
 func testFlagConfigUpdate(testConfig TestConfig) (string, error) {
 	tmpl, err := template.New("").Parse(hclCommon + hclAudiences + `
 	resource "optimizely_feature" "dynamic_forms_terraform" {
@@ -300,20 +833,25 @@ func testFlagConfigUpdate(testConfig TestConfig) (string, error) {
 }
 
 func testAccCheckHashicupsOrderDestroy(s *terraform.State) error {
-	// c := testAccProvider.Meta().(client.OptimizelyClient)
+	c := testAccProvider.Meta().(client.OptimizelyClient)
 
 	for _, rs := range s.RootModule().Resources {
 		if rs.Type != "optimizely_feature" {
 			continue
 		}
 
-		// flagId := rs.Primary.Attributes["key"]
+		projectId, err := strconv.Atoi(rs.Primary.Attributes["project"])
+		if err != nil {
+			return err
+		}
 
-		// err := c.DeleteFlag(20410805626, flagId)
-		// if err != nil {
-		// 	fmt.Printf("\n delete flag error >>>>>>>>> %+v \n", err)
-		// 	return err
-		// }
+		_, err = c.GetFlag(context.Background(), projectId, rs.Primary.Attributes["key"])
+		if err == nil {
+			return fmt.Errorf("flag %s still exists", rs.Primary.ID)
+		}
+		if !apierror.IsNotFound(err) {
+			return err
+		}
 	}
 
 	return nil
@@ -321,23 +859,99 @@ func testAccCheckHashicupsOrderDestroy(s *terraform.State) error {
 
 func TestFlagBasic(t *testing.T) {
 	testConfig := TestConfig{
+		Provider:     testAccProviderConfig(t),
 		AudienceName: strings.ToUpper(gofakeit.BS()),
 		FlagKey:      gofakeit.BS(),
 	}
 
 	hcl, _ := testFlagConfigBasic(testConfig)
-	// hclUpdate, _ := testFlagConfigUpdate(testConfig)
+	hclInPlace, _ := testFlagConfigInPlaceUpdate(testConfig)
+
+	var flagId string
 
 	resource.Test(t, resource.TestCase{
-		Providers:    testAccProviders,
-		CheckDestroy: testAccCheckHashicupsOrderDestroy,
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      testAccCheckHashicupsOrderDestroy,
 		Steps: []resource.TestStep{
 			{
 				Config: hcl,
 				Check: resource.ComposeTestCheckFunc(
 					testAccCheckHashicupsOrderExists("optimizely_feature.dynamic_forms_terraform"),
+					testAccCheckResourceId("optimizely_feature.dynamic_forms_terraform", &flagId),
+				),
+			},
+			{
+				ResourceName:      "optimizely_feature.dynamic_forms_terraform",
+				ImportState:       true,
+				ImportStateVerify: true,
+			},
+			{
+				Config: hclInPlace,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPtr("optimizely_feature.dynamic_forms_terraform", "id", &flagId),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "description", testConfig.FlagKey+" - Terraform - Updated"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "variable_schema.0.variable.#", "3"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "variations.0.variation.#", "2"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "rules.0.rule.2.type", "a/b"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "rules.0.rule.2.variation.#", "2"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "rules.0.rule.2.metric.0.primary", "true"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "rules.0.rule.2.metric.1.aggregator", "sum"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "rules.0.rule.3.key", "us-prod"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "rules.0.rule.3.audience_conditions.0.operator", "and"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "rules.0.rule.3.audience_conditions.0.group.1.operator", "not"),
+					resource.TestCheckTypeSetElemNestedAttrs("optimizely_feature.dynamic_forms_terraform", "environment.*", map[string]string{
+						"key":     "prod",
+						"enabled": "false",
+					}),
+				),
+			},
+			{
+				// removed variations are archived once no rule serves them
+				Config: hcl,
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttrPtr("optimizely_feature.dynamic_forms_terraform", "id", &flagId),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "variable_schema.0.variable.#", "2"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "variations.0.variation.#", "1"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "rules.0.rule.#", "3"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "rules.0.rule.2.key", "br-uat"),
+					resource.TestCheckResourceAttr("optimizely_feature.dynamic_forms_terraform", "environment.#", "0"),
 				),
 			},
+			{
+				// disabling the flag in the UI shows up as drift
+				PreConfig: func() {
+					c := testAccProvider.Meta().(client.OptimizelyClient)
+					err := c.DisableRuleset(context.Background(), flag.Flag{
+						ProjectId:    testAccProjectId,
+						Key:          testConfig.FlagKey,
+						Environments: map[string]flag.FeatureEnvironment{"sit": {}},
+					})
+					if err != nil {
+						t.Fatalf("failed to disable flag %s in sit: %s", testConfig.FlagKey, err)
+					}
+				},
+				Config:             hcl,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+			{
+				// so does enabling it in an environment without rules, which
+				// destroy disables again before deleting the flag
+				PreConfig: func() {
+					c := testAccProvider.Meta().(client.OptimizelyClient)
+					err := c.EnableRuleset(context.Background(), flag.Flag{
+						ProjectId:    testAccProjectId,
+						Key:          testConfig.FlagKey,
+						Environments: map[string]flag.FeatureEnvironment{"dev": {}},
+					})
+					if err != nil {
+						t.Fatalf("failed to enable flag %s in dev: %s", testConfig.FlagKey, err)
+					}
+				},
+				Config:             hcl,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
 			// {
 			// 	Config: hclUpdate,
 			// 	Check: resource.ComposeTestCheckFunc(
@@ -347,3 +961,237 @@ func TestFlagBasic(t *testing.T) {
 		},
 	})
 }
+
+func TestAccFlagArchiveOnDestroy(t *testing.T) {
+	testConfig := TestConfig{
+		Provider: testAccProviderConfig(t),
+		FlagKey:  gofakeit.BS(),
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy: func(s *terraform.State) error {
+			c := testAccProvider.Meta().(client.OptimizelyClient)
+
+			feat, err := c.GetFlag(context.Background(), testAccProjectId, testConfig.FlagKey)
+			if err != nil {
+				return err
+			}
+
+			if !feat.Archived {
+				return fmt.Errorf("expected flag %s to be archived", testConfig.FlagKey)
+			}
+
+			return nil
+		},
+		Steps: []resource.TestStep{
+			{
+				Config: testConfig.Provider + fmt.Sprintf(`
+				resource "optimizely_feature" "archived" {
+					name               = "%[1]s"
+					description        = "%[1]s"
+					key                = "%[1]s"
+					archive_on_destroy = true
+
+					rules {
+						rule {
+							key                 = "everyone"
+							environments        = ["sit"]
+							audience            = []
+							percentage_included = 100
+							deliver             = "on"
+						}
+					}
+				}
+				`, testConfig.FlagKey),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("optimizely_feature.archived", "archive_on_destroy", "true"),
+				),
+			},
+		},
+	})
+}
+
+func TestAccFlagCreateFailure(t *testing.T) {
+	testConfig := TestConfig{
+		Provider: testAccProviderConfig(t),
+		FlagKey:  gofakeit.BS(),
+	}
+
+	config := func(variables string) string {
+		return testConfig.Provider + fmt.Sprintf(`
+		resource "optimizely_feature" "partial" {
+			name        = "%[1]s"
+			description = "%[1]s"
+			key         = "%[1]s"
+
+			variable_schema {
+				variable {
+					key           = "color"
+					type          = "string"
+					default_value = "black"
+				}
+				%[2]s
+			}
+
+			variations {
+				variation {
+					key       = "big"
+					name      = "big"
+					variables = {
+						size = "big"
+					}
+				}
+			}
+		}
+		`, testConfig.FlagKey, variables)
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		CheckDestroy:      testAccCheckHashicupsOrderDestroy,
+		Steps: []resource.TestStep{
+			{
+				// the flag is created, its variation isn't
+				Config:      config(""),
+				ExpectError: regexp.MustCompile(`variable size isn't defined by the flag`),
+			},
+			{
+				// the tainted flag is replaced rather than conflicting
+				Config: config(`
+				variable {
+					key           = "size"
+					type          = "string"
+					default_value = "small"
+				}
+				`),
+				Check: resource.ComposeTestCheckFunc(
+					resource.TestCheckResourceAttr("optimizely_feature.partial", "key", testConfig.FlagKey),
+				),
+			},
+		},
+	})
+}
+
+func TestAccFlagRuleValidation(t *testing.T) {
+	provider := testAccProviderConfig(t)
+
+	config := func(rule string) string {
+		return provider + `
+		resource "optimizely_feature" "invalid" {
+			name        = "invalid"
+			description = "invalid"
+			key         = "invalid"
+
+			rules {
+				rule {
+					key                 = "test"
+					environments        = ["sit"]
+					audience            = []
+					percentage_included = 100
+					` + rule + `
+				}
+			}
+		}
+		`
+	}
+
+	resource.Test(t, resource.TestCase{
+		ProviderFactories: testAccProviderFactories,
+		Steps: []resource.TestStep{
+			{
+				Config:      config(`type = "targeted_delivery"`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`rule test: targeted_delivery rules need deliver`),
+			},
+			{
+				Config: config(`
+					type = "a/b"
+					variation {
+						key    = "on"
+						weight = 60
+					}
+					variation {
+						key    = "off"
+						weight = 30
+					}
+				`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`rule test: variation weights must add up to 100, got 90`),
+			},
+			{
+				Config: config(`
+					deliver = "on"
+					audience_conditions {
+						operator  = "not"
+						audiences = ["1", "2"]
+					}
+				`),
+				PlanOnly:    true,
+				ExpectError: regexp.MustCompile(`rule test: a "not" group negates exactly one audience or group, got 2`),
+			},
+			{
+				// deliver is only known once the audience is created
+				Config: config(`
+					deliver = optimizely_audience.gate.id != "" ? "on" : "off"
+				`) + `
+				resource "optimizely_audience" "gate" {
+					name       = "GATE_TERRAFORM"
+					conditions = jsonencode(["and"])
+				}
+				`,
+				PlanOnly:           true,
+				ExpectNonEmptyPlan: true,
+			},
+		},
+	})
+}
+
+func TestResolveToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(tokenFile, []byte("file-token\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv(TokenEnvVar, "env-token")
+
+	cases := map[string]struct {
+		config map[string]interface{}
+		token  string
+	}{
+		"token":         {map[string]interface{}{"token": "config-token"}, "config-token"},
+		"token_file":    {map[string]interface{}{"token_file": tokenFile}, "file-token"},
+		"token_command": {map[string]interface{}{"token_command": []interface{}{"echo", "command-token"}}, "command-token"},
+		"environment":   {map[string]interface{}{}, "env-token"},
+	}
+
+	for name, c := range cases {
+		d := schema.TestResourceDataRaw(t, Provider().Schema, c.config)
+
+		token, diags := resolveToken(context.Background(), d)
+		if diags.HasError() {
+			t.Fatalf("%s: unexpected error: %v", name, diags)
+		}
+
+		if token != c.token {
+			t.Errorf("%s: expected token %q, got %q", name, c.token, token)
+		}
+	}
+
+	failures := map[string]struct {
+		config  map[string]interface{}
+		summary string
+	}{
+		"missing token_file":    {map[string]interface{}{"token_file": filepath.Join(t.TempDir(), "missing")}, "Failed to read token_file"},
+		"failing token_command": {map[string]interface{}{"token_command": []interface{}{"false"}}, "token_command failed"},
+	}
+
+	for name, c := range failures {
+		d := schema.TestResourceDataRaw(t, Provider().Schema, c.config)
+
+		_, diags := resolveToken(context.Background(), d)
+		if !diags.HasError() || diags[0].Summary != c.summary {
+			t.Errorf("%s: expected an error summarized %q, got %v", name, c.summary, diags)
+		}
+	}
+}